@@ -0,0 +1,120 @@
+// Package batch runs per-chapter work on a bounded worker pool while
+// reporting results in chapter order.
+package batch
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Exit codes for a batch run.
+const (
+	ExitSuccess = 0 // All chapters processed
+	ExitPartial = 1 // Some chapters failed
+	ExitError   = 2 // All chapters failed, or nothing to process
+)
+
+// Result is the outcome of processing one chapter.
+// Workers buffer their console output in Output instead of printing it,
+// so that the report callback can print it in chapter order.
+type Result struct {
+	Output  string // Buffered console output for the chapter
+	Skipped bool   // Chapter was skipped without error (e.g. no images)
+	Parts   int    // Archives written for the chapter; 0 counts as 1 on success
+	Err     error  // Non-nil if the chapter failed
+}
+
+// Summary counts the outcomes of a batch run.
+type Summary struct {
+	Total     int
+	Succeeded int
+	Skipped   int
+	Failed    int
+	Parts     int // Archives written by succeeded chapters
+}
+
+// ExitCode maps the summary to a process exit code: 0 if nothing failed,
+// 2 if every chapter failed (or there were none), 1 otherwise.
+// Skipped chapters do not count as failures.
+func (s Summary) ExitCode() int {
+	switch {
+	case s.Total == 0:
+		return ExitError
+	case s.Failed == 0:
+		return ExitSuccess
+	case s.Failed == s.Total:
+		return ExitError
+	default:
+		return ExitPartial
+	}
+}
+
+// add records one result in the summary.
+func (s *Summary) add(r Result) {
+	s.Total++
+	switch {
+	case r.Err != nil:
+		s.Failed++
+	case r.Skipped:
+		s.Skipped++
+	default:
+		s.Succeeded++
+		s.Parts += max(r.Parts, 1)
+	}
+}
+
+// Run processes items 0..n-1 using up to jobs concurrent workers.
+// If jobs is less than 1, runtime.NumCPU() workers are used.
+//
+// process is called concurrently and must be safe for that. report, if
+// non-nil, is called from the calling goroutine in index order: result i
+// is reported as soon as it and every result before it are available.
+// A failing item does not stop the others.
+func Run(n, jobs int, process func(i int) Result, report func(i int, r Result)) Summary {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	results := make([]Result, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	// Feed indexes in order so early chapters finish first
+	indexes := make(chan int)
+	go func() {
+		for i := 0; i < n; i++ {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = process(i)
+				close(done[i])
+			}
+		}()
+	}
+
+	// Report in order while workers keep running
+	var summary Summary
+	for i := 0; i < n; i++ {
+		<-done[i]
+		if report != nil {
+			report(i, results[i])
+		}
+		summary.add(results[i])
+	}
+
+	wg.Wait()
+	return summary
+}
@@ -0,0 +1,120 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun_ReportsInOrder(t *testing.T) {
+	const n = 20
+
+	// Later items finish first to force out-of-order completion
+	process := func(i int) Result {
+		time.Sleep(time.Duration(n-i) * time.Millisecond)
+		return Result{Output: fmt.Sprintf("chapter %d", i)}
+	}
+
+	var reported []int
+	summary := Run(n, 8, process, func(i int, r Result) {
+		if r.Output != fmt.Sprintf("chapter %d", i) {
+			t.Errorf("result %d has output %q", i, r.Output)
+		}
+		reported = append(reported, i)
+	})
+
+	if len(reported) != n {
+		t.Fatalf("expected %d reports, got %d", n, len(reported))
+	}
+	for i, idx := range reported {
+		if idx != i {
+			t.Fatalf("report order = %v, want ascending", reported)
+		}
+	}
+	if summary.Succeeded != n || summary.Total != n {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRun_BoundsConcurrency(t *testing.T) {
+	var running, peak int32
+
+	process := func(i int) Result {
+		cur := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return Result{}
+	}
+
+	Run(12, 3, process, nil)
+
+	if peak > 3 {
+		t.Errorf("peak concurrency = %d, want <= 3", peak)
+	}
+}
+
+func TestRun_FailuresDoNotStopOthers(t *testing.T) {
+	process := func(i int) Result {
+		switch i {
+		case 1:
+			return Result{Err: errors.New("boom")}
+		case 2:
+			return Result{Skipped: true}
+		case 3:
+			return Result{Parts: 3}
+		}
+		return Result{}
+	}
+
+	summary := Run(4, 2, process, nil)
+
+	// A split chapter counts each of its parts
+	want := Summary{Total: 4, Succeeded: 2, Skipped: 1, Failed: 1, Parts: 4}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if summary.ExitCode() != ExitPartial {
+		t.Errorf("ExitCode() = %d, want %d", summary.ExitCode(), ExitPartial)
+	}
+}
+
+func TestRun_Empty(t *testing.T) {
+	summary := Run(0, 4, func(int) Result {
+		t.Error("process should not be called")
+		return Result{}
+	}, nil)
+
+	if summary.Total != 0 {
+		t.Errorf("Total = %d, want 0", summary.Total)
+	}
+}
+
+func TestSummary_ExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary Summary
+		want    int
+	}{
+		{"all succeeded", Summary{Total: 3, Succeeded: 3}, ExitSuccess},
+		{"only skipped", Summary{Total: 1, Skipped: 1}, ExitSuccess},
+		{"some failed", Summary{Total: 3, Succeeded: 2, Failed: 1}, ExitPartial},
+		{"all failed", Summary{Total: 2, Failed: 2}, ExitError},
+		{"nothing to do", Summary{}, ExitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.ExitCode(); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package cbz
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+)
+
+// Format selects the output backend.
+type Format string
+
+// Output formats.
+const (
+	FormatCBZ  Format = "cbz"  // ZIP archive
+	FormatCBT  Format = "cbt"  // Tar archive
+	FormatDir  Format = "dir"  // Plain folder of pages
+	FormatEPUB Format = "epub" // EPUB 3 fixed-layout book
+	FormatPDF  Format = "pdf"  // PDF document
+)
+
+// Formats lists the output formats in the order shown to users.
+var Formats = []Format{FormatCBZ, FormatCBT, FormatDir, FormatEPUB, FormatPDF}
+
+// ParseFormat parses an output format name (case-insensitive).
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(s))
+	for _, known := range Formats {
+		if f == known {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q (want one of cbz, cbt, dir, epub, pdf)", s)
+}
+
+// Ext returns the extension of output paths in this format, for use with
+// naming.Plan; folders have none.
+func (f Format) Ext() string {
+	if f == FormatDir {
+		return ""
+	}
+	return "." + string(f)
+}
+
+// ArchiveWriter writes the pages of one chapter or volume to an output
+// in some format. Begin starts an output, AddMetadata and AddPage fill
+// it, and Commit makes it visible at the path given to Begin, replacing
+// any existing output. Until then nothing is visible at that path; Abort
+// discards an unfinished output. A writer can be reused after Commit or
+// Abort.
+//
+// AddMetadata must come before the first page. Metadata is written as
+// given; CreateWith fills in the page list first.
+type ArchiveWriter interface {
+	Begin(outputPath string) error
+	AddMetadata(info ComicInfo) error
+	AddPage(img chapter.ImageFile) error
+	Commit() error
+	Abort() error
+}
+
+// NewWriter returns the ArchiveWriter for format. opts.Force and
+// opts.Deterministic apply to every format; opts.Comment only to CBZ.
+// Metadata and the size limits are applied by CreateWith and Create,
+// not by the writer.
+func NewWriter(format Format, opts CreateOptions) (ArchiveWriter, error) {
+	switch format {
+	case FormatCBZ:
+		return &zipArchive{opts: opts}, nil
+	case FormatCBT:
+		return &tarArchive{opts: opts}, nil
+	case FormatDir:
+		return &dirArchive{opts: opts}, nil
+	case FormatEPUB, FormatPDF:
+		return &bookArchive{format: format, opts: opts}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// CreateWith writes images to outputPath with w, as Create does for CBZ:
+// opts.Metadata, if set, is written first with its page list filled in
+// from images. The output is aborted if any step fails. opts.MaxSize and
+// opts.MaxPages are not applied; use Create to split CBZ archives.
+func CreateWith(w ArchiveWriter, outputPath string, images []chapter.ImageFile, opts CreateOptions) error {
+	if err := w.Begin(outputPath); err != nil {
+		return err
+	}
+
+	err := addPages(w, images, opts.Metadata)
+	if err == nil {
+		err = w.Commit()
+	}
+	if err != nil {
+		w.Abort()
+	}
+	return err
+}
+
+// addPages adds metadata, if non-nil, with its page list filled in from
+// images, and then the images to a begun writer.
+func addPages(w ArchiveWriter, images []chapter.ImageFile, metadata *ComicInfo) error {
+	if metadata != nil {
+		if err := w.AddMetadata(metadata.withPages(images)); err != nil {
+			return err
+		}
+	}
+	for _, img := range images {
+		if err := w.AddPage(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errNotBegun is returned by writers used outside Begin and Commit.
+var errNotBegun = errors.New("archive writer used before Begin")
+
+// checkExisting returns an error if outputPath exists and force is off.
+func checkExisting(outputPath string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, statErr := os.Stat(outputPath); statErr == nil {
+		return errors.New("file already exists: " + outputPath)
+	}
+	return nil
+}
+
+// zipArchive writes CBZ archives; Create and CreateParts use it for
+// every archive they write.
+type zipArchive struct {
+	opts CreateOptions
+	path string
+	file *os.File
+	zw   *zip.Writer
+}
+
+// Begin implements ArchiveWriter.
+func (a *zipArchive) Begin(outputPath string) error {
+	if err := checkExisting(outputPath, a.opts.Force); err != nil {
+		return err
+	}
+
+	f, err := createTemp(outputPath)
+	if err != nil {
+		return err
+	}
+	a.path, a.file, a.zw = outputPath, f, zip.NewWriter(f)
+	if a.opts.Comment != "" {
+		if err := a.zw.SetComment(a.opts.Comment); err != nil {
+			a.Abort()
+			return err
+		}
+	}
+	return nil
+}
+
+// AddMetadata implements ArchiveWriter.
+func (a *zipArchive) AddMetadata(info ComicInfo) error {
+	if a.zw == nil {
+		return errNotBegun
+	}
+	return addComicInfoToArchive(a.zw, info, a.opts.Deterministic)
+}
+
+// AddPage implements ArchiveWriter.
+func (a *zipArchive) AddPage(img chapter.ImageFile) error {
+	if a.zw == nil {
+		return errNotBegun
+	}
+	return addImageToArchive(a.zw, img, a.opts.Deterministic)
+}
+
+// Commit implements ArchiveWriter.
+func (a *zipArchive) Commit() error {
+	temp, err := a.finish()
+	if err != nil {
+		return err
+	}
+	return commitTemp(temp, a.path)
+}
+
+// finish completes the archive in its temporary file, without making it
+// visible, and returns the file's path. The caller renames it into place
+// with commitTemp, or removes it.
+func (a *zipArchive) finish() (string, error) {
+	if a.zw == nil {
+		return "", errNotBegun
+	}
+	if err := a.zw.Close(); err != nil {
+		a.Abort()
+		return "", err
+	}
+
+	f, temp := a.file, a.file.Name()
+	a.zw, a.file = nil, nil
+	if err := finishTemp(f); err != nil {
+		os.Remove(temp)
+		return "", err
+	}
+	return temp, nil
+}
+
+// Abort implements ArchiveWriter.
+func (a *zipArchive) Abort() error {
+	if a.file == nil {
+		return nil
+	}
+	a.file.Close()
+	err := os.Remove(a.file.Name())
+	a.zw, a.file = nil, nil
+	return err
+}
+
+// bookArchive collects pages and writes them with CreateEPUB or
+// CreatePDF on Commit. Books read right to left when the metadata says
+// the pages are manga read right to left.
+type bookArchive struct {
+	format Format
+	opts   CreateOptions
+	path   string
+	info   *ComicInfo
+	images []chapter.ImageFile
+	begun  bool
+}
+
+// Begin implements ArchiveWriter.
+func (a *bookArchive) Begin(outputPath string) error {
+	if err := checkExisting(outputPath, a.opts.Force); err != nil {
+		return err
+	}
+	a.path, a.info, a.images, a.begun = outputPath, nil, nil, true
+	return nil
+}
+
+// AddMetadata implements ArchiveWriter.
+func (a *bookArchive) AddMetadata(info ComicInfo) error {
+	if !a.begun {
+		return errNotBegun
+	}
+	a.info = &info
+	return nil
+}
+
+// AddPage implements ArchiveWriter.
+func (a *bookArchive) AddPage(img chapter.ImageFile) error {
+	if !a.begun {
+		return errNotBegun
+	}
+	a.images = append(a.images, img)
+	return nil
+}
+
+// Commit implements ArchiveWriter.
+func (a *bookArchive) Commit() error {
+	if !a.begun {
+		return errNotBegun
+	}
+	a.begun = false
+
+	if a.format == FormatPDF {
+		return CreatePDF(a.path, a.images, PDFOptions{Force: true, Metadata: a.info, Deterministic: a.opts.Deterministic})
+	}
+	rtl := a.info != nil && a.info.Manga == MangaRightToLeft
+	return CreateEPUB(a.path, a.images, EPUBOptions{Force: true, Metadata: a.info, RightToLeft: rtl, Deterministic: a.opts.Deterministic})
+}
+
+// Abort implements ArchiveWriter.
+func (a *bookArchive) Abort() error {
+	a.begun, a.info, a.images = false, nil, nil
+	return nil
+}
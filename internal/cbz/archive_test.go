@@ -0,0 +1,146 @@
+package cbz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantExt string
+		wantErr bool
+	}{
+		{"cbz", FormatCBZ, ".cbz", false},
+		{"CBT", FormatCBT, ".cbt", false},
+		{"dir", FormatDir, "", false},
+		{"epub", FormatEPUB, ".epub", false},
+		{"pdf", FormatPDF, ".pdf", false},
+		{"rar", "", "", true},
+		{"", "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || (err == nil && got.Ext() != tt.wantExt) {
+			t.Errorf("ParseFormat(%q) = %q (ext %q), want %q (ext %q)", tt.in, got, got.Ext(), tt.want, tt.wantExt)
+		}
+	}
+}
+
+func TestCreateWith_AllFormats(t *testing.T) {
+	srcDir := t.TempDir()
+	images := []chapter.ImageFile{
+		createPNG(t, srcDir, "1.png", 8, 12),
+		createPNG(t, srcDir, "2.png", 8, 12),
+	}
+	// A converted page, as produced by convert.StreamImages
+	images = append(images, chapter.ImageFile{Path: images[1].Path, Name: "3.png", Source: stringSource{content: string(pngBytes(t, 8, 12))}})
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			outDir := t.TempDir()
+			outputPath := filepath.Join(outDir, "Chapter 1"+format.Ext())
+			opts := CreateOptions{Metadata: &ComicInfo{Title: "Chapter 1"}}
+
+			w, err := NewWriter(format, opts)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := CreateWith(w, outputPath, images, opts); err != nil {
+				t.Fatalf("CreateWith() error = %v", err)
+			}
+			if _, err := os.Stat(outputPath); err != nil {
+				t.Fatalf("output missing: %v", err)
+			}
+			assertNoTempFiles(t, outDir)
+
+			// Existing output is kept without Force
+			if err := CreateWith(w, outputPath, images, opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Errorf("CreateWith() over existing output error = %v", err)
+			}
+
+			// And replaced with it
+			opts.Force = true
+			if w, err = NewWriter(format, opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := CreateWith(w, outputPath, images[:1], opts); err != nil {
+				t.Errorf("CreateWith() with Force error = %v", err)
+			}
+			assertNoTempFiles(t, outDir)
+		})
+	}
+}
+
+func TestCreateWith_AbortsOnFailure(t *testing.T) {
+	srcDir := t.TempDir()
+	images := []chapter.ImageFile{
+		createPNG(t, srcDir, "1.png", 8, 8),
+		{Path: filepath.Join(srcDir, "1.png"), Name: "2.png", Source: stringSource{err: os.ErrInvalid}},
+	}
+
+	for _, format := range []Format{FormatCBZ, FormatCBT, FormatDir} {
+		t.Run(string(format), func(t *testing.T) {
+			outDir := t.TempDir()
+			outputPath := filepath.Join(outDir, "out"+format.Ext())
+			w, _ := NewWriter(format, CreateOptions{})
+			if err := CreateWith(w, outputPath, images, CreateOptions{}); err == nil {
+				t.Fatal("expected error from failing source")
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Error("failed output should not be visible")
+			}
+			assertNoTempFiles(t, outDir)
+
+			// The writer is usable again after the abort
+			if err := CreateWith(w, outputPath, images[:1], CreateOptions{}); err != nil {
+				t.Errorf("CreateWith() after abort error = %v", err)
+			}
+		})
+	}
+}
+
+func TestZipArchive_MatchesCreate(t *testing.T) {
+	tmpDir, images := createTestImages(t, 3)
+	opts := CreateOptions{Metadata: &ComicInfo{Title: "T"}, Comment: "note", Deterministic: true}
+
+	created := filepath.Join(tmpDir, "create.cbz")
+	if err := Create(created, images, opts); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	written := filepath.Join(tmpDir, "writer.cbz")
+	w, _ := NewWriter(FormatCBZ, opts)
+	if err := CreateWith(w, written, images, opts); err != nil {
+		t.Fatalf("CreateWith() error = %v", err)
+	}
+
+	a, _ := os.ReadFile(created)
+	b, _ := os.ReadFile(written)
+	if string(a) != string(b) {
+		t.Error("CBZ writer output differs from Create")
+	}
+}
+
+func TestWriter_UseBeforeBegin(t *testing.T) {
+	for _, format := range Formats {
+		w, _ := NewWriter(format, CreateOptions{})
+		if err := w.AddPage(chapter.ImageFile{}); err != errNotBegun {
+			t.Errorf("%s: AddPage() before Begin error = %v", format, err)
+		}
+		if err := w.Commit(); err != errNotBegun {
+			t.Errorf("%s: Commit() before Begin error = %v", format, err)
+		}
+		if err := w.Abort(); err != nil {
+			t.Errorf("%s: Abort() before Begin error = %v", format, err)
+		}
+	}
+}
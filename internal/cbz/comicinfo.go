@@ -0,0 +1,201 @@
+package cbz
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"image"
+	_ "image/gif"  // Register GIF decoder for page dimensions
+	_ "image/jpeg" // Register JPEG decoder for page dimensions
+	_ "image/png"  // Register PNG decoder for page dimensions
+	"strconv"
+	"time"
+
+	_ "golang.org/x/image/bmp"  // Register BMP decoder for page dimensions
+	_ "golang.org/x/image/webp" // Register WebP decoder for page dimensions
+
+	"manga2cbz/internal/chapter"
+)
+
+// ComicInfoName is the archive entry name readers look for.
+const ComicInfoName = "ComicInfo.xml"
+
+// Values for ComicInfo.Manga, which encodes the reading direction.
+const (
+	MangaUnknown     = "Unknown"
+	MangaNo          = "No"                // Left-to-right comic
+	MangaYes         = "Yes"               // Manga, direction unspecified
+	MangaRightToLeft = "YesAndRightToLeft" // Manga read right-to-left
+)
+
+// PageType classifies a page in ComicInfo.xml.
+type PageType string
+
+// Page types defined by the ComicInfo schema.
+const (
+	PageFrontCover    PageType = "FrontCover"
+	PageInnerCover    PageType = "InnerCover"
+	PageRoundup       PageType = "Roundup"
+	PageStory         PageType = "Story"
+	PageAdvertisement PageType = "Advertisement"
+	PageEditorial     PageType = "Editorial"
+	PageLetters       PageType = "Letters"
+	PagePreview       PageType = "Preview"
+	PageBackCover     PageType = "BackCover"
+	PageOther         PageType = "Other"
+	PageDeleted       PageType = "Deleted"
+)
+
+// ComicInfo is the metadata written to ComicInfo.xml (ComicInfo schema v2.0).
+// Fields are declared in schema order, since the schema uses xs:sequence.
+// Zero values are omitted from the output.
+type ComicInfo struct {
+	XMLName     xml.Name `xml:"ComicInfo" json:"-"`
+	Title       string   `xml:"Title,omitempty" json:"title,omitempty"`
+	Series      string   `xml:"Series,omitempty" json:"series,omitempty"`
+	Number      string   `xml:"Number,omitempty" json:"number,omitempty"`
+	Volume      int      `xml:"Volume,omitempty" json:"volume,omitempty"`
+	Notes       string   `xml:"Notes,omitempty" json:"notes,omitempty"`
+	Writer      string   `xml:"Writer,omitempty" json:"writer,omitempty"`
+	PageCount   int      `xml:"PageCount,omitempty" json:"page_count,omitempty"`
+	LanguageISO string   `xml:"LanguageISO,omitempty" json:"language_iso,omitempty"`
+	Manga       string   `xml:"Manga,omitempty" json:"manga,omitempty"`
+	Pages       PageList `xml:"Pages,omitempty" json:"pages,omitempty"`
+}
+
+// PageList is the <Pages> element of ComicInfo.xml.
+// It is a named type so that an empty list omits the element entirely.
+type PageList []PageInfo
+
+// MarshalXML writes the list as <Pages><Page .../>...</Pages>.
+func (pl PageList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	wrapper := struct {
+		Page []PageInfo `xml:"Page"`
+	}{Page: pl}
+	return e.EncodeElement(wrapper, start)
+}
+
+// UnmarshalXML reads the <Page> children of a <Pages> element.
+func (pl *PageList) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var wrapper struct {
+		Page []PageInfo `xml:"Page"`
+	}
+	if err := d.DecodeElement(&wrapper, &start); err != nil {
+		return err
+	}
+	*pl = append(*pl, wrapper.Page...)
+	return nil
+}
+
+// PageInfo describes a single page of the archive.
+// Image is the zero-based index of the page among the archived images.
+type PageInfo struct {
+	Image       int      `xml:"Image,attr" json:"image"`
+	Type        PageType `xml:"Type,attr,omitempty" json:"type,omitempty"`
+	DoublePage  bool     `xml:"DoublePage,attr,omitempty" json:"double_page,omitempty"`
+	ImageSize   int64    `xml:"ImageSize,attr,omitempty" json:"image_size,omitempty"`
+	Bookmark    string   `xml:"Bookmark,attr,omitempty" json:"bookmark,omitempty"` // Shown in the reader's table of contents
+	ImageWidth  int      `xml:"ImageWidth,attr,omitempty" json:"image_width,omitempty"`
+	ImageHeight int      `xml:"ImageHeight,attr,omitempty" json:"image_height,omitempty"`
+}
+
+// withPages returns a copy of info with PageCount and Pages filled in from images.
+// Caller-supplied Type, DoublePage and Bookmark values are kept for
+// matching indexes.
+// Page dimensions are left empty for images whose header cannot be decoded,
+// and ImageSize is left empty for images produced by a Source.
+func (info ComicInfo) withPages(images []chapter.ImageFile) ComicInfo {
+	given := make(map[int]PageInfo, len(info.Pages))
+	for _, p := range info.Pages {
+		given[p.Image] = p
+	}
+
+	info.PageCount = len(images)
+	info.Pages = make(PageList, len(images))
+	for i, img := range images {
+		page := given[i]
+		page.Image = i
+		if fi, err := img.Stat(); err == nil && img.Source == nil {
+			page.ImageSize = fi.Size()
+		}
+		if cfg, err := pageConfig(img); err == nil {
+			page.ImageWidth = cfg.Width
+			page.ImageHeight = cfg.Height
+		}
+		info.Pages[i] = page
+	}
+
+	return info
+}
+
+// configSource is implemented by image sources that know the dimensions
+// of the content they produce, which may differ from the file at Path
+// (e.g. when pages are resized).
+type configSource interface {
+	Config() (image.Config, error)
+}
+
+// pageConfig returns the dimensions of an archived image.
+func pageConfig(img chapter.ImageFile) (image.Config, error) {
+	if cs, ok := img.Source.(configSource); ok {
+		return cs.Config()
+	}
+	return decodeConfig(img)
+}
+
+// decodeConfig reads only the image header to get its dimensions.
+func decodeConfig(img chapter.ImageFile) (image.Config, error) {
+	f, err := img.Open()
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
+
+// MarshalComicInfo serializes info as an indented ComicInfo.xml document.
+func MarshalComicInfo(info ComicInfo) ([]byte, error) {
+	body, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// addComicInfoToArchive writes info as the ComicInfo.xml entry.
+// Unlike images, the XML compresses well, so it is deflated.
+func addComicInfoToArchive(zw *zip.Writer, info ComicInfo, deterministic bool) error {
+	data, err := MarshalComicInfo(info)
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:   ComicInfoName,
+		Method: zip.Deflate,
+	}
+	setModTime(header, time.Now(), deterministic)
+
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+	return err
+}
+
+// NewComicInfo returns metadata populated from the chapter's parsed
+// series, volume, number and title. Non-integer volumes are omitted.
+func NewComicInfo(ch chapter.Chapter) *ComicInfo {
+	info := &ComicInfo{
+		Title:  ch.Title,
+		Series: ch.Series,
+		Number: ch.Number,
+	}
+	if volume, err := strconv.Atoi(ch.Volume); err == nil {
+		info.Volume = volume
+	}
+	return info
+}
@@ -0,0 +1,228 @@
+package cbz
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// readComicInfo returns the decoded ComicInfo.xml and the archive entry names.
+func readComicInfo(t *testing.T, cbzPath string) (ComicInfo, []string) {
+	t.Helper()
+	reader, err := zip.OpenReader(cbzPath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+
+	var names []string
+	var info ComicInfo
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+		if f.Name != ComicInfoName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", ComicInfoName, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", ComicInfoName, err)
+		}
+		if err := xml.Unmarshal(data, &info); err != nil {
+			t.Fatalf("failed to parse %s: %v", ComicInfoName, err)
+		}
+	}
+	return info, names
+}
+
+func TestCreate_WithMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{
+		createPNG(t, tmpDir, "01.png", 800, 1200),
+		createPNG(t, tmpDir, "02.png", 1600, 1200),
+	}
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	meta := &ComicInfo{
+		Series: "Test Series",
+		Number: "21.5",
+		Volume: 3,
+		Manga:  MangaRightToLeft,
+		Pages: []PageInfo{
+			{Image: 0, Type: PageFrontCover},
+			{Image: 1, DoublePage: true, Bookmark: "Chapter 2"},
+		},
+	}
+
+	if err := Create(outputPath, images, CreateOptions{Metadata: meta}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	info, names := readComicInfo(t, outputPath)
+
+	// ComicInfo.xml must be the first entry
+	want := []string{ComicInfoName, "01.png", "02.png"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+
+	if info.Series != "Test Series" || info.Number != "21.5" || info.Volume != 3 {
+		t.Errorf("unexpected series fields: %+v", info)
+	}
+	if info.Manga != MangaRightToLeft {
+		t.Errorf("Manga = %q, want %q", info.Manga, MangaRightToLeft)
+	}
+	if info.PageCount != 2 {
+		t.Errorf("PageCount = %d, want 2", info.PageCount)
+	}
+	if len(info.Pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(info.Pages))
+	}
+	if info.Pages[0].Type != PageFrontCover {
+		t.Errorf("page 0 Type = %q, want %q", info.Pages[0].Type, PageFrontCover)
+	}
+	if info.Pages[1].Bookmark != "Chapter 2" {
+		t.Errorf("page 1 Bookmark = %q, want %q", info.Pages[1].Bookmark, "Chapter 2")
+	}
+	if !info.Pages[1].DoublePage {
+		t.Error("page 1 should be marked DoublePage")
+	}
+	if info.Pages[1].ImageWidth != 1600 || info.Pages[1].ImageHeight != 1200 {
+		t.Errorf("page 1 dimensions = %dx%d, want 1600x1200",
+			info.Pages[1].ImageWidth, info.Pages[1].ImageHeight)
+	}
+	if info.Pages[0].ImageSize == 0 {
+		t.Error("page 0 ImageSize should be filled in")
+	}
+
+	// Caller's metadata must not be modified
+	if meta.PageCount != 0 {
+		t.Error("Create() modified the caller's metadata")
+	}
+}
+
+func TestCreate_MetadataUndecodableImage(t *testing.T) {
+	tmpDir, images := createTestImages(t, 1)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	err := Create(outputPath, images, CreateOptions{Metadata: &ComicInfo{Title: "x"}})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	info, _ := readComicInfo(t, outputPath)
+	if info.PageCount != 1 {
+		t.Errorf("PageCount = %d, want 1", info.PageCount)
+	}
+	if info.Pages[0].ImageWidth != 0 || info.Pages[0].ImageHeight != 0 {
+		t.Errorf("expected no dimensions for fake image, got %dx%d",
+			info.Pages[0].ImageWidth, info.Pages[0].ImageHeight)
+	}
+}
+
+func TestMarshalComicInfo_OmitsEmptyFields(t *testing.T) {
+	data, err := MarshalComicInfo(ComicInfo{Series: "A & B"})
+	if err != nil {
+		t.Fatalf("MarshalComicInfo() failed: %v", err)
+	}
+
+	out := string(data)
+	if !strings.HasPrefix(out, "<?xml") {
+		t.Error("output should start with an XML declaration")
+	}
+	if !strings.Contains(out, "<Series>A &amp; B</Series>") {
+		t.Errorf("series not escaped correctly:\n%s", out)
+	}
+	for _, tag := range []string{"<Title>", "<Volume>", "<Pages>", "<Manga>"} {
+		if strings.Contains(out, tag) {
+			t.Errorf("empty field %s should be omitted:\n%s", tag, out)
+		}
+	}
+}
+
+func TestMarshalComicInfo_FieldOrder(t *testing.T) {
+	data, err := MarshalComicInfo(ComicInfo{
+		Title:       "T",
+		Series:      "S",
+		Number:      "1",
+		Volume:      2,
+		Writer:      "W",
+		PageCount:   1,
+		LanguageISO: "ja",
+		Manga:       MangaYes,
+	})
+	if err != nil {
+		t.Fatalf("MarshalComicInfo() failed: %v", err)
+	}
+
+	// Schema uses xs:sequence, so elements must appear in this order
+	order := []string{"<Title>", "<Series>", "<Number>", "<Volume>", "<Writer>", "<PageCount>", "<LanguageISO>", "<Manga>"}
+	out := string(data)
+	last := -1
+	for _, tag := range order {
+		idx := strings.Index(out, tag)
+		if idx < last {
+			t.Errorf("%s is out of schema order:\n%s", tag, out)
+		}
+		last = idx
+	}
+}
+
+func TestNewComicInfo(t *testing.T) {
+	info := NewComicInfo(chapter.Chapter{
+		Series: "One Piece",
+		Volume: "3",
+		Number: "21.5",
+		Title:  "The Storm",
+	})
+
+	if info.Series != "One Piece" || info.Volume != 3 || info.Number != "21.5" || info.Title != "The Storm" {
+		t.Errorf("unexpected ComicInfo: %+v", info)
+	}
+
+	// Fractional volumes cannot be represented and are omitted
+	if info := NewComicInfo(chapter.Chapter{Volume: "1.5"}); info.Volume != 0 {
+		t.Errorf("Volume = %d, want 0 for fractional volume", info.Volume)
+	}
+}
+
+// sizedSource is a chapter.Source that reports its own dimensions.
+type sizedSource struct {
+	stringSource
+	width, height int
+}
+
+func (s sizedSource) Config() (image.Config, error) {
+	return image.Config{Width: s.width, Height: s.height}, nil
+}
+
+func TestCreate_MetadataUsesSourceDimensions(t *testing.T) {
+	tmpDir := t.TempDir()
+	img := createPNG(t, tmpDir, "01.png", 400, 600)
+
+	// A resizing source produces smaller pages than the original file
+	img.Source = sizedSource{stringSource: stringSource{content: "resized"}, width: 100, height: 150}
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	if err := Create(outputPath, []chapter.ImageFile{img}, CreateOptions{Metadata: &ComicInfo{}}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	info, _ := readComicInfo(t, outputPath)
+	if info.Pages[0].ImageWidth != 100 || info.Pages[0].ImageHeight != 150 {
+		t.Errorf("page dimensions = %dx%d, want 100x150",
+			info.Pages[0].ImageWidth, info.Pages[0].ImageHeight)
+	}
+	if info.Pages[0].ImageSize != 0 {
+		t.Errorf("ImageSize = %d, want 0 for generated page", info.Pages[0].ImageSize)
+	}
+}
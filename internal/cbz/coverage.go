@@ -0,0 +1,211 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/convert"
+	"manga2cbz/internal/naming"
+)
+
+// CoverageOptions configures checking archives against their sources.
+// The settings must match those the archives were built with.
+type CoverageOptions struct {
+	Recursive  bool             // Discover chapters recursively
+	Archives   bool             // ZIP archives in inputDir are chapters too
+	Template   *naming.Template // Output naming; nil means naming.DefaultTemplate
+	Extensions []string         // Image extensions; empty means chapter.DefaultExtensions
+	Convert    convert.Options  // Conversion applied when the archives were built
+	Renumber   bool             // Entries were renamed with Renumber
+
+	// HashConverted re-encodes converted pages to compare their content.
+	// Encoding is deterministic, so this detects any difference, but it
+	// costs a full conversion. Otherwise converted pages are matched by
+	// name only; copied pages are always compared by content hash.
+	HashConverted bool
+}
+
+// CheckCoverage discovers the chapters under inputDir, as a build would,
+// and checks each one's archive under outputDir with CompareSource.
+// Returns one report per chapter, in discovery order.
+func CheckCoverage(inputDir, outputDir string, opts CoverageOptions) ([]VerifyReport, error) {
+	chapters, err := chapter.DiscoverWith(inputDir, chapter.DiscoverOptions{Recursive: opts.Recursive, IncludeArchives: opts.Archives})
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := opts.Template
+	if tmpl == nil {
+		if tmpl, err = naming.Parse(naming.DefaultTemplate); err != nil {
+			return nil, err
+		}
+	}
+	paths, err := naming.Plan(chapters, tmpl, outputDir, ".cbz")
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = chapter.DefaultExtensions
+	}
+
+	reports := make([]VerifyReport, len(chapters))
+	for i, ch := range chapters {
+		images, err := chapter.CollectImages(ch.Path, extensions)
+		if err != nil {
+			reports[i] = VerifyReport{Path: paths[i], Problems: []Problem{{Message: fmt.Sprintf("cannot read source %s: %v", ch.Path, err)}}}
+			continue
+		}
+		reports[i] = CompareSource(paths[i], images, opts)
+	}
+	return reports, nil
+}
+
+// CompareSource checks that the archive at archivePath holds every page
+// built from images exactly once, and nothing else. Expected pages are
+// derived from images with opts.Convert, so converted, split and resized
+// pages are expected under their new names. Missing, extra, duplicated
+// and mismatched pages are reported. With opts.Renumber, pages are
+// expected under the names Renumber gives them. An archive split by
+// CreateParts is checked as a whole, reading the pages of every part
+// in order.
+func CompareSource(archivePath string, images []chapter.ImageFile, opts CoverageOptions) VerifyReport {
+	report := VerifyReport{Path: archivePath}
+	add := func(entry, format string, args ...interface{}) {
+		report.Problems = append(report.Problems, Problem{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	paths := Parts(archivePath)
+	if len(images) == 0 {
+		if len(paths) > 0 {
+			add("", "archive exists but the source has no pages")
+		}
+		return report
+	}
+
+	expected, err := convert.StreamImages(images, opts.Convert)
+	if err != nil {
+		add("", "cannot convert source: %v", err)
+		return report
+	}
+	if opts.Renumber {
+		expected, _ = Renumber(expected)
+	}
+
+	// Gather the entries of every part; a missing archive fails to open
+	if len(paths) == 0 {
+		paths = []string{archivePath}
+	} else if len(paths) > 1 && paths[0] == archivePath {
+		add("", "both the archive and its parts exist")
+	}
+	var files []*zip.File
+	for _, path := range paths {
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			add("", "cannot open archive: %v", err)
+			return report
+		}
+		defer reader.Close()
+		files = append(files, reader.File...)
+	}
+
+	// Index archive pages by name; metadata is not a page
+	entries := make(map[string][]*zip.File)
+	var names []string
+	for _, f := range files {
+		if f.Name == ComicInfoName || f.FileInfo().IsDir() {
+			continue
+		}
+		if _, seen := entries[f.Name]; !seen {
+			names = append(names, f.Name)
+		}
+		entries[f.Name] = append(entries[f.Name], f)
+		report.Pages++
+	}
+
+	wanted := make(map[string]bool, len(expected))
+	for _, img := range expected {
+		wanted[img.Name] = true
+
+		files := entries[img.Name]
+		switch {
+		case len(files) == 0:
+			add(img.Name, "missing from archive")
+			continue
+		case len(files) > 1:
+			add(img.Name, "stored %d times", len(files))
+		}
+
+		if img.Source != nil && !opts.HashConverted {
+			continue
+		}
+		if err := comparePage(files[0], img); err != nil {
+			add(img.Name, "%v", err)
+		}
+	}
+
+	for _, name := range names {
+		if !wanted[name] {
+			add(name, "not in source")
+		}
+	}
+
+	if report.Pages != len(expected) {
+		add("", "%d pages in archive, %d expected", report.Pages, len(expected))
+	}
+	return report
+}
+
+// errMismatch reports a page whose content differs from its source.
+var errMismatch = errors.New("content differs from source")
+
+// comparePage compares the content hash of an archive entry with the
+// page built from its source.
+func comparePage(f *zip.File, img chapter.ImageFile) error {
+	got, err := hashEntry(f)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if img.Source != nil {
+		if err := img.Source.Encode(h); err != nil {
+			return fmt.Errorf("cannot convert source: %v", err)
+		}
+	} else {
+		src, err := img.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if _, err := io.Copy(h, src); err != nil {
+			return err
+		}
+	}
+
+	if !bytes.Equal(h.Sum(nil), got) {
+		return errMismatch
+	}
+	return nil
+}
+
+// hashEntry returns the SHA-256 digest of an archive entry's content.
+func hashEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
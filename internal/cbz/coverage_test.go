@@ -0,0 +1,205 @@
+package cbz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/convert"
+)
+
+// buildChapter creates a chapter folder under inputDir with PNG pages and
+// builds its archive under outputDir the way a run with opts would.
+// Returns the source images and the archive path.
+func buildChapter(t *testing.T, inputDir, outputDir, name string, pages int, opts convert.Options) ([]chapter.ImageFile, string) {
+	t.Helper()
+	dir := filepath.Join(inputDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create chapter: %v", err)
+	}
+	for i := 0; i < pages; i++ {
+		createPNG(t, dir, string(rune('1'+i))+".png", 8+i, 8)
+	}
+	images, err := chapter.CollectImages(dir, chapter.DefaultExtensions)
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+
+	converted, err := convert.StreamImages(images, opts)
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+	archive := filepath.Join(outputDir, name+".cbz")
+	if err := Create(archive, converted, CreateOptions{Metadata: &ComicInfo{Title: name}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return images, archive
+}
+
+func TestCheckCoverage_AllPagesPresent(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	toJPEG := convert.Options{Policy: convert.Policy{Targets: map[string]convert.Format{"png": convert.FormatJPEG}}}
+	buildChapter(t, inputDir, outputDir, "Chapter 1", 3, toJPEG)
+	buildChapter(t, inputDir, outputDir, "Chapter 2", 2, toJPEG)
+
+	reports, err := CheckCoverage(inputDir, outputDir, CoverageOptions{Convert: toJPEG, HashConverted: true})
+	if err != nil {
+		t.Fatalf("CheckCoverage() error = %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+	for _, r := range reports {
+		if !r.OK() {
+			t.Errorf("%s problems = %+v, want none", r.Path, r.Problems)
+		}
+	}
+	if reports[0].Pages != 3 || reports[1].Pages != 2 {
+		t.Errorf("pages = %d, %d; want 3, 2", reports[0].Pages, reports[1].Pages)
+	}
+
+	// Without the conversion settings, every page looks missing
+	reports, err = CheckCoverage(inputDir, outputDir, CoverageOptions{})
+	if err != nil {
+		t.Fatalf("CheckCoverage() error = %v", err)
+	}
+	if msg := problemFor(reports[0], "1.png"); msg != "missing from archive" {
+		t.Errorf("problem for 1.png = %q, want missing", msg)
+	}
+	if msg := problemFor(reports[0], "1.jpg"); msg != "not in source" {
+		t.Errorf("problem for 1.jpg = %q, want not in source", msg)
+	}
+}
+
+func TestCheckCoverage_SplitChapter(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	images, archive := buildChapter(t, inputDir, outputDir, "Chapter 1", 3, convert.Options{})
+	paths, err := CreateParts(archive, images, CreateOptions{Force: true, MaxPages: 2})
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("CreateParts() = %v, %v; want 2 parts", paths, err)
+	}
+
+	reports, err := CheckCoverage(inputDir, outputDir, CoverageOptions{})
+	if err != nil {
+		t.Fatalf("CheckCoverage() error = %v", err)
+	}
+	if !reports[0].OK() || reports[0].Pages != 3 {
+		t.Errorf("report = %d pages, %+v; want 3 pages and no problems", reports[0].Pages, reports[0].Problems)
+	}
+
+	// A missing part is a broken archive
+	if err := os.Remove(paths[1]); err != nil {
+		t.Fatal(err)
+	}
+	report := CompareSource(archive, images, CoverageOptions{})
+	if msg := problemFor(report, ""); !strings.Contains(msg, "cannot open archive") {
+		t.Errorf("problem = %q, want cannot open archive", msg)
+	}
+}
+
+func TestCompareSource_ReportsDifferences(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	images, archive := buildChapter(t, inputDir, outputDir, "Chapter 1", 3, convert.Options{})
+
+	// Rebuild the archive with page 2 missing, page 3 edited and a stray file
+	stray := filepath.Join(t.TempDir(), "credits.txt")
+	if err := os.WriteFile(stray, []byte("scanned by"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	edited := createPNG(t, t.TempDir(), "3.png", 99, 99)
+	rebuilt := []chapter.ImageFile{
+		images[0],
+		{Path: edited.Path, Name: "3.png"},
+		{Path: stray, Name: "credits.txt"},
+		images[0],
+	}
+	if err := Create(archive, rebuilt, CreateOptions{Force: true}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	report := CompareSource(archive, images, CoverageOptions{})
+	want := map[string]string{
+		"1.png":       "stored 2 times",
+		"2.png":       "missing from archive",
+		"3.png":       "content differs from source",
+		"credits.txt": "not in source",
+		"":            "4 pages in archive, 3 expected",
+	}
+	for entry, msg := range want {
+		if got := problemFor(report, entry); got != msg {
+			t.Errorf("problem for %q = %q, want %q", entry, got, msg)
+		}
+	}
+}
+
+func TestCompareSource_ConvertedPages(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	toJPEG := convert.Options{Policy: convert.Policy{Targets: map[string]convert.Format{"png": convert.FormatJPEG}}}
+	images, archive := buildChapter(t, inputDir, outputDir, "Chapter 1", 1, toJPEG)
+
+	// Replace the converted page with other content under the same name
+	other := createPNG(t, t.TempDir(), "other.png", 50, 50)
+	if err := Create(archive, []chapter.ImageFile{{Path: other.Path, Name: "1.jpg"}}, CreateOptions{Force: true}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if report := CompareSource(archive, images, CoverageOptions{Convert: toJPEG}); !report.OK() {
+		t.Errorf("converted pages should be matched by name only, got %+v", report.Problems)
+	}
+	report := CompareSource(archive, images, CoverageOptions{Convert: toJPEG, HashConverted: true})
+	if msg := problemFor(report, "1.jpg"); msg != "content differs from source" {
+		t.Errorf("problem for 1.jpg = %q, want content mismatch", msg)
+	}
+}
+
+func TestCompareSource_MissingArchive(t *testing.T) {
+	inputDir := t.TempDir()
+	images, _ := buildChapter(t, inputDir, t.TempDir(), "Chapter 1", 1, convert.Options{})
+
+	report := CompareSource(filepath.Join(t.TempDir(), "missing.cbz"), images, CoverageOptions{})
+	if msg := problemFor(report, ""); !strings.Contains(msg, "cannot open archive") {
+		t.Errorf("problem = %q, want cannot open archive", msg)
+	}
+}
+
+func TestCheckCoverage_ArchiveChapters(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	page := pngBytes(t, 12, 16)
+	writeZip(t, filepath.Join(inputDir, "Chapter 1.zip"),
+		zipEntry{"scans/2.png", page},
+		zipEntry{"scans/10.png", page},
+		zipEntry{"scans/readme.txt", []byte("junk")},
+	)
+
+	// Re-pack the messy archive into a clean one
+	chapters, err := chapter.DiscoverWith(inputDir, chapter.DiscoverOptions{IncludeArchives: true})
+	if err != nil || len(chapters) != 1 {
+		t.Fatalf("Discover() = %+v, %v; want one chapter", chapters, err)
+	}
+	images, err := chapter.CollectImages(chapters[0].Path, chapter.DefaultExtensions)
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+	archive := filepath.Join(outputDir, "Chapter 1.cbz")
+	if err := Create(archive, images, CreateOptions{Metadata: &ComicInfo{}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info, names := readComicInfo(t, archive)
+	if len(names) != 3 || names[1] != "2.png" || names[2] != "10.png" {
+		t.Errorf("archive entries = %v, want ComicInfo.xml, 2.png, 10.png", names)
+	}
+	if info.Pages[0].ImageWidth != 12 || info.Pages[0].ImageSize != int64(len(page)) {
+		t.Errorf("page info = %+v, want entry size and dimensions", info.Pages[0])
+	}
+
+	reports, err := CheckCoverage(inputDir, outputDir, CoverageOptions{Archives: true})
+	if err != nil {
+		t.Fatalf("CheckCoverage() error = %v", err)
+	}
+	if !reports[0].OK() {
+		t.Errorf("problems = %+v, want none", reports[0].Problems)
+	}
+}
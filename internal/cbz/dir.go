@@ -0,0 +1,173 @@
+package cbz
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/naming"
+)
+
+// dirArchive writes pages as plain files in a folder at the output path,
+// under their archive entry names, with ComicInfo.xml alongside.
+//
+// The folder is filled under a temporary name and renamed into place on
+// Commit. With Force, an existing folder at the output path is replaced
+// as a whole: its old contents are removed. Pages read from inside the
+// output folder are refused, so a chapter folder is never replaced by
+// its own output; naming.Plan refuses such paths up front.
+type dirArchive struct {
+	opts CreateOptions
+	path string
+	temp string
+}
+
+// Begin implements ArchiveWriter.
+func (a *dirArchive) Begin(outputPath string) error {
+	if err := checkExisting(outputPath, a.opts.Force); err != nil {
+		return err
+	}
+
+	dir, name := splitPath(outputPath)
+	removeTemp(dir, name)
+	temp, err := os.MkdirTemp(dir, "."+name+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	a.path, a.temp = outputPath, temp
+	return nil
+}
+
+// AddMetadata implements ArchiveWriter.
+func (a *dirArchive) AddMetadata(info ComicInfo) error {
+	if a.temp == "" {
+		return errNotBegun
+	}
+	data, err := MarshalComicInfo(info)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.temp, ComicInfoName)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	return a.setModTime(path, time.Now())
+}
+
+// AddPage implements ArchiveWriter.
+func (a *dirArchive) AddPage(img chapter.ImageFile) error {
+	if a.temp == "" {
+		return errNotBegun
+	}
+
+	// Replacing the folder would delete the page's own source
+	if naming.ContainsPath(a.path, img.Path) {
+		return fmt.Errorf("page %s is inside the output folder %s", img.Name, a.path)
+	}
+
+	info, err := img.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Entry names are flat, so pages cannot escape the folder
+	path := filepath.Join(a.temp, filepath.Base(img.Name))
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if img.Source != nil {
+		err = img.Source.Encode(out)
+	} else {
+		err = copyFile(out, img)
+	}
+	if err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return a.setModTime(path, info.ModTime())
+}
+
+// copyFile copies the content of img to w.
+func copyFile(w io.Writer, img chapter.ImageFile) error {
+	src, err := img.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// setModTime gives the file at path modification time t, or
+// DeterministicTime for deterministic output.
+func (a *dirArchive) setModTime(path string, t time.Time) error {
+	if a.opts.Deterministic {
+		t = DeterministicTime
+	}
+	return os.Chtimes(path, t, t)
+}
+
+// Commit implements ArchiveWriter.
+func (a *dirArchive) Commit() error {
+	if a.temp == "" {
+		return errNotBegun
+	}
+	temp := a.temp
+	a.temp = ""
+
+	// A folder cannot be renamed over another; move the old one aside
+	// first, and remove it only once the new one is in place
+	var old string
+	if _, err := os.Lstat(a.path); err == nil {
+		dir, name := splitPath(a.path)
+		if old, err = os.MkdirTemp(dir, "."+name+".*"+tempSuffix); err != nil {
+			os.RemoveAll(temp)
+			return err
+		}
+		os.Remove(old)
+		if err := os.Rename(a.path, old); err != nil {
+			os.RemoveAll(temp)
+			return err
+		}
+	}
+
+	if err := os.Chmod(temp, 0o755); err != nil {
+		os.RemoveAll(temp)
+		return err
+	}
+	if err := os.Rename(temp, a.path); err != nil {
+		if old != "" {
+			os.Rename(old, a.path)
+		}
+		os.RemoveAll(temp)
+		return err
+	}
+
+	dir, _ := splitPath(a.path)
+	syncDir(dir)
+	if old != "" {
+		os.RemoveAll(old)
+	}
+	return nil
+}
+
+// Abort implements ArchiveWriter.
+func (a *dirArchive) Abort() error {
+	if a.temp == "" {
+		return nil
+	}
+	err := os.RemoveAll(a.temp)
+	a.temp = ""
+	return err
+}
@@ -0,0 +1,97 @@
+package cbz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestDirArchive(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+	images = append(images, chapter.ImageFile{Path: images[0].Path, Name: "page3.jpg", Source: stringSource{content: "converted"}})
+	outputPath := filepath.Join(tmpDir, "Chapter 1")
+
+	opts := CreateOptions{Metadata: &ComicInfo{Title: "T"}}
+	w, _ := NewWriter(FormatDir, opts)
+	if err := CreateWith(w, outputPath, images, opts); err != nil {
+		t.Fatalf("CreateWith() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("folder holds %d files, want 4", len(entries))
+	}
+	original, _ := os.ReadFile(images[1].Path)
+	if copied, _ := os.ReadFile(filepath.Join(outputPath, images[1].Name)); string(copied) != string(original) {
+		t.Error("copied page differs from its source")
+	}
+	if converted, _ := os.ReadFile(filepath.Join(outputPath, "page3.jpg")); string(converted) != "converted" {
+		t.Errorf("converted page = %q", converted)
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, ComicInfoName)); err != nil {
+		t.Errorf("metadata missing: %v", err)
+	}
+
+	// Replacing the folder drops files the new output does not have
+	opts.Force = true
+	w, _ = NewWriter(FormatDir, opts)
+	if err := CreateWith(w, outputPath, images[:1], opts); err != nil {
+		t.Fatalf("CreateWith() with Force error = %v", err)
+	}
+	if entries, _ := os.ReadDir(outputPath); len(entries) != 2 {
+		t.Errorf("replaced folder holds %d files, want 2", len(entries))
+	}
+	assertNoTempFiles(t, tmpDir)
+}
+
+func TestDirArchive_RefusesSourceFolder(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+
+	// Folder output over the chapter's own folder must not delete it
+	opts := CreateOptions{Force: true}
+	w, _ := NewWriter(FormatDir, opts)
+	if err := CreateWith(w, tmpDir, images, opts); err == nil {
+		t.Fatal("expected error for output over the source folder")
+	}
+	for _, img := range images {
+		if _, err := os.Stat(img.Path); err != nil {
+			t.Errorf("source page %s is gone: %v", img.Name, err)
+		}
+	}
+	assertNoTempFiles(t, filepath.Dir(tmpDir))
+}
+
+func TestRemoveStaleTemp_Folders(t *testing.T) {
+	tmpDir := t.TempDir()
+	stale := filepath.Join(tmpDir, ".Chapter 1.123"+tempSuffix)
+	// A hidden folder of the user's that only looks temporary
+	kept := filepath.Join(tmpDir, ".Chapter 2.old"+tempSuffix)
+	for _, dir := range []string{stale, kept} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "1.jpg"), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputs := []string{filepath.Join(tmpDir, "Chapter 1"), filepath.Join(tmpDir, "Chapter 2")}
+	removed, err := RemoveStaleTemp(outputs)
+	if err != nil {
+		t.Fatalf("RemoveStaleTemp() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != stale {
+		t.Errorf("removed = %v, want [%s]", removed, stale)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale folder was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(kept, "1.jpg")); err != nil {
+		t.Error("folder not written by the tool should be kept")
+	}
+}
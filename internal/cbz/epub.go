@@ -0,0 +1,362 @@
+package cbz
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"manga2cbz/internal/chapter"
+)
+
+// EPUBOptions configures EPUB book creation.
+type EPUBOptions struct {
+	Force bool // Overwrite existing files if true
+
+	// Metadata supplies the book's title, author and language; Series and
+	// Number stand in for a missing title, and the file name for both.
+	// Bookmarked pages (see PageInfo.Bookmark) become the chapter entries
+	// of the table of contents. May be nil.
+	Metadata *ComicInfo
+
+	RightToLeft   bool // Turn pages right to left, as for manga
+	Deterministic bool // As for CreateOptions.Deterministic
+}
+
+// Media types of the raster images that are EPUB 3 core media types,
+// which reading systems must display without a fallback.
+var epubMediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// Paths inside the EPUB container.
+const (
+	epubMimetype  = "application/epub+zip"
+	epubContainer = "META-INF/container.xml"
+	epubPackage   = "OEBPS/content.opf"
+	epubNav       = "OEBPS/nav.xhtml"
+)
+
+// epubPage is one image of the book and the XHTML page showing it.
+type epubPage struct {
+	img       chapter.ImageFile
+	image     string // Image path relative to the package document
+	xhtml     string // Page path relative to the package document
+	mediaType string
+	width     int
+	height    int
+}
+
+// CreateEPUB creates an EPUB 3 fixed-layout book at outputPath with one
+// page per image, in order, as an alternative to a CBZ archive. Each page
+// is an XHTML document sized to its image, which is stored unmodified.
+// The first image is declared as the cover, and the navigation document
+// lists the bookmarked pages of opts.Metadata, or just the first page.
+//
+// The book is written atomically, as Create writes archives. It is an
+// error if images is empty, a page's dimensions cannot be read, or a
+// page is not JPEG, PNG, GIF or WebP; convert other formats such as BMP
+// to PNG first.
+func CreateEPUB(outputPath string, images []chapter.ImageFile, opts EPUBOptions) error {
+	if err := checkExisting(outputPath, opts.Force); err != nil {
+		return err
+	}
+
+	if len(images) == 0 {
+		return errors.New("no pages for EPUB: " + outputPath)
+	}
+
+	pages, err := epubPages(images)
+	if err != nil {
+		return err
+	}
+
+	temp, err := writeTemp(outputPath, func(w io.Writer) error {
+		return writeEPUB(w, pages, epubTitle(outputPath, opts.Metadata), opts)
+	})
+	if err != nil {
+		return err
+	}
+	return commitTemp(temp, outputPath)
+}
+
+// epubPages lays out the book's files and reads each page's dimensions.
+func epubPages(images []chapter.ImageFile) ([]epubPage, error) {
+	width := len(fmt.Sprint(len(images)))
+
+	pages := make([]epubPage, len(images))
+	for i, img := range images {
+		ext := strings.ToLower(filepath.Ext(img.Name))
+		mediaType, ok := epubMediaTypes[ext]
+		if !ok {
+			return nil, fmt.Errorf("unsupported image type for EPUB: %s (convert it to PNG or JPEG)", img.Name)
+		}
+
+		cfg, err := pageConfig(img)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read page size of %s: %v", img.Name, err)
+		}
+
+		// Files are numbered, so paths need no escaping
+		pages[i] = epubPage{
+			img:       img,
+			image:     fmt.Sprintf("images/%0*d%s", width, i+1, ext),
+			xhtml:     fmt.Sprintf("pages/%0*d.xhtml", width, i+1),
+			mediaType: mediaType,
+			width:     cfg.Width,
+			height:    cfg.Height,
+		}
+	}
+	return pages, nil
+}
+
+// epubTitle returns the book title from info, or from the file name.
+func epubTitle(outputPath string, info *ComicInfo) string {
+	if info != nil {
+		switch {
+		case info.Title != "":
+			return info.Title
+		case info.Series != "" && info.Number != "":
+			return info.Series + " " + info.Number
+		case info.Series != "":
+			return info.Series
+		}
+	}
+	name := filepath.Base(outputPath)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// writeEPUB writes the EPUB container with the given pages to w.
+func writeEPUB(w io.Writer, pages []epubPage, title string, opts EPUBOptions) error {
+	zipWriter := zip.NewWriter(w)
+
+	modified := time.Now().UTC()
+	if opts.Deterministic {
+		modified = DeterministicTime
+	}
+
+	// The mimetype entry must come first, stored and without extra fields
+	if err := addMimetype(zipWriter, modified); err != nil {
+		return err
+	}
+
+	// Text documents: container, package, navigation, then the pages
+	if err := addDocument(zipWriter, epubContainer, containerXML, modified, opts.Deterministic); err != nil {
+		return err
+	}
+	if err := addDocument(zipWriter, epubPackage, packageDocument(pages, title, modified, opts), modified, opts.Deterministic); err != nil {
+		return err
+	}
+	if err := addDocument(zipWriter, epubNav, navDocument(pages, title, opts.Metadata), modified, opts.Deterministic); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := addDocument(zipWriter, "OEBPS/"+page.xhtml, pageDocument(page, title), modified, opts.Deterministic); err != nil {
+			return err
+		}
+	}
+
+	// Images are already compressed; store them under their new names
+	for _, page := range pages {
+		img := page.img
+		img.Name = "OEBPS/" + page.image
+		if err := addImageToArchive(zipWriter, img, opts.Deterministic); err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// addMimetype writes the mimetype entry. It is written raw so that no
+// data descriptor follows it and its content starts at byte 38 of the
+// file, where reading systems look for it.
+func addMimetype(zw *zip.Writer, modified time.Time) error {
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(epubMimetype)),
+		CompressedSize64:   uint64(len(epubMimetype)),
+		UncompressedSize64: uint64(len(epubMimetype)),
+	}
+	header.ModifiedDate, header.ModifiedTime = dosTime(modified)
+
+	writer, err := zw.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, epubMimetype)
+	return err
+}
+
+// addDocument writes a deflated text entry.
+func addDocument(zw *zip.Writer, name, data string, modified time.Time, deterministic bool) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	setModTime(header, modified, deterministic)
+
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, data)
+	return err
+}
+
+// containerXML points reading systems at the package document.
+const containerXML = xml.Header + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="` + epubPackage + `" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument returns the OPF package document: metadata, a manifest
+// of every file and the spine giving the page order.
+func packageDocument(pages []epubPage, title string, modified time.Time, opts EPUBOptions) string {
+	language := "und"
+	var creator string
+	if opts.Metadata != nil {
+		if opts.Metadata.LanguageISO != "" {
+			language = opts.Metadata.LanguageISO
+		}
+		creator = opts.Metadata.Writer
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<package version="3.0" unique-identifier="book-id" xmlns="http://www.idpf.org/2007/opf">` + "\n")
+	b.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", bookID(pages, title))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", escapeXML(title))
+	fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", escapeXML(language))
+	if creator != "" {
+		fmt.Fprintf(&b, "    <dc:creator>%s</dc:creator>\n", escapeXML(creator))
+	}
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", modified.Format("2006-01-02T15:04:05Z"))
+	b.WriteString(`    <meta property="rendition:layout">pre-paginated</meta>` + "\n")
+	b.WriteString(`    <meta property="rendition:orientation">auto</meta>` + "\n")
+	b.WriteString(`    <meta property="rendition:spread">none</meta>` + "\n")
+	b.WriteString(`    <meta name="cover" content="image-1"/>` + "\n")
+	b.WriteString("  </metadata>\n")
+
+	b.WriteString("  <manifest>\n")
+	b.WriteString(`    <item id="nav" href="` + strings.TrimPrefix(epubNav, "OEBPS/") + `" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	for i, page := range pages {
+		properties := ""
+		if i == 0 {
+			properties = ` properties="cover-image"`
+		}
+		fmt.Fprintf(&b, "    <item id=\"image-%d\" href=\"%s\" media-type=\"%s\"%s/>\n", i+1, page.image, page.mediaType, properties)
+		fmt.Fprintf(&b, "    <item id=\"page-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, page.xhtml)
+	}
+	b.WriteString("  </manifest>\n")
+
+	direction := "ltr"
+	if opts.RightToLeft {
+		direction = "rtl"
+	}
+	fmt.Fprintf(&b, "  <spine page-progression-direction=\"%s\">\n", direction)
+	for i := range pages {
+		fmt.Fprintf(&b, "    <itemref idref=\"page-%d\"/>\n", i+1)
+	}
+	b.WriteString("  </spine>\n")
+	b.WriteString("</package>\n")
+	return b.String()
+}
+
+// bookID derives a stable identifier from the title and page names, so
+// rebuilding a book does not make readers treat it as a new one.
+func bookID(pages []epubPage, title string) string {
+	h := sha256.New()
+	io.WriteString(h, title)
+	for _, page := range pages {
+		io.WriteString(h, "\x00"+page.img.Name)
+	}
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50 // Version 5 (name-based)
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// navDocument returns the navigation document, with a table of contents
+// entry for each bookmarked page in info, or for the first page.
+func navDocument(pages []epubPage, title string, info *ComicInfo) string {
+	type entry struct {
+		label string
+		page  int
+	}
+	var entries []entry
+	if info != nil {
+		for _, p := range info.Pages {
+			if p.Bookmark != "" && p.Image >= 0 && p.Image < len(pages) {
+				entries = append(entries, entry{p.Bookmark, p.Image})
+			}
+		}
+	}
+	if len(entries) == 0 {
+		entries = append(entries, entry{title, 0})
+	}
+
+	var b strings.Builder
+	b.WriteString(xhtmlHeader(title, ""))
+	b.WriteString(`  <nav epub:type="toc" id="toc">` + "\n")
+	fmt.Fprintf(&b, "    <h1>%s</h1>\n", escapeXML(title))
+	b.WriteString("    <ol>\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "      <li><a href=\"%s\">%s</a></li>\n", pages[e.page].xhtml, escapeXML(e.label))
+	}
+	b.WriteString("    </ol>\n")
+	b.WriteString("  </nav>\n")
+	b.WriteString(`  <nav epub:type="landmarks" id="landmarks" hidden="">` + "\n")
+	b.WriteString("    <ol>\n")
+	fmt.Fprintf(&b, "      <li><a epub:type=\"cover\" href=\"%s\">Cover</a></li>\n", pages[0].xhtml)
+	b.WriteString("    </ol>\n")
+	b.WriteString("  </nav>\n")
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// pageDocument returns the fixed-layout XHTML page showing one image at
+// its own size.
+func pageDocument(page epubPage, title string) string {
+	viewport := fmt.Sprintf(`  <meta name="viewport" content="width=%d, height=%d"/>`+"\n", page.width, page.height)
+
+	var b strings.Builder
+	b.WriteString(xhtmlHeader(title, viewport))
+	fmt.Fprintf(&b, "  <img src=\"../%s\" alt=\"\" style=\"display:block;width:%dpx;height:%dpx\"/>\n", page.image, page.width, page.height)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// xhtmlHeader starts an XHTML document, up to the opening body tag.
+// head is added to the document head.
+func xhtmlHeader(title, head string) string {
+	return xml.Header + `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>` + escapeXML(title) + `</title>
+` + head + `  <style>html,body{margin:0;padding:0}</style>
+</head>
+<body>
+`
+}
+
+// escapeXML escapes s for use in XML text and attribute values.
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
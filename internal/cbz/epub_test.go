@@ -0,0 +1,204 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestCreateEPUB_Structure(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{
+		createPNG(t, tmpDir, "cover & front.png", 80, 120),
+		createPNG(t, tmpDir, "p2.png", 160, 120),
+		createPNG(t, tmpDir, "p3.png", 80, 120),
+	}
+	outputPath := filepath.Join(tmpDir, "Vol 1.epub")
+
+	meta := &ComicInfo{
+		Title:       "Tom & Jerry",
+		Writer:      "Someone",
+		LanguageISO: "ja",
+		Pages: PageList{
+			{Image: 0, Bookmark: "Chapter 1"},
+			{Image: 2, Bookmark: "Chapter 2: <Finale>"},
+		},
+	}
+	if err := CreateEPUB(outputPath, images, EPUBOptions{Metadata: meta, RightToLeft: true}); err != nil {
+		t.Fatalf("CreateEPUB() error = %v", err)
+	}
+
+	// The mimetype entry comes first, stored, at the fixed offset
+	raw, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(raw[30:58]); got != "mimetypeapplication/epub+zip" {
+		t.Errorf("bytes 30-58 = %q, want mimetype entry", got)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := reader.File[0]; f.Name != "mimetype" || f.Method != zip.Store || len(f.Extra) != 0 {
+		t.Errorf("first entry = %s (method %d, %d extra bytes), want stored mimetype", f.Name, f.Method, len(f.Extra))
+	}
+
+	content, names := readEntries(t, outputPath)
+	for _, name := range []string{epubContainer, epubPackage, epubNav, "OEBPS/pages/1.xhtml", "OEBPS/pages/3.xhtml", "OEBPS/images/1.png", "OEBPS/images/3.png"} {
+		if _, ok := content[name]; !ok {
+			t.Errorf("missing entry %s in %v", name, names)
+		}
+	}
+
+	// Every document is well-formed XML
+	for name, data := range content {
+		if !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".xhtml") {
+			continue
+		}
+		d := xml.NewDecoder(strings.NewReader(data))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed: %v", name, err)
+				break
+			}
+		}
+	}
+
+	opf := content[epubPackage]
+	for _, want := range []string{
+		`<dc:title>Tom &amp; Jerry</dc:title>`,
+		`<dc:language>ja</dc:language>`,
+		`<dc:creator>Someone</dc:creator>`,
+		`<meta property="rendition:layout">pre-paginated</meta>`,
+		`href="images/1.png" media-type="image/png" properties="cover-image"`,
+		`properties="nav"`,
+		`<spine page-progression-direction="rtl">`,
+		`<itemref idref="page-1"/>`,
+		`<itemref idref="page-3"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document missing %s", want)
+		}
+	}
+
+	nav := content[epubNav]
+	for _, want := range []string{
+		`<a href="pages/1.xhtml">Chapter 1</a>`,
+		`<a href="pages/3.xhtml">Chapter 2: &lt;Finale&gt;</a>`,
+	} {
+		if !strings.Contains(nav, want) {
+			t.Errorf("navigation document missing %s", want)
+		}
+	}
+
+	page := content["OEBPS/pages/2.xhtml"]
+	for _, want := range []string{`content="width=160, height=120"`, `src="../images/2.png"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page 2 missing %s", want)
+		}
+	}
+
+	// Images are stored unmodified
+	original, _ := os.ReadFile(images[1].Path)
+	if content["OEBPS/images/2.png"] != string(original) {
+		t.Error("page 2 image differs from its source")
+	}
+}
+
+func TestCreateEPUB_Defaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{createPNG(t, tmpDir, "a.png", 10, 10)}
+	outputPath := filepath.Join(tmpDir, "Chapter 5.epub")
+
+	if err := CreateEPUB(outputPath, images, EPUBOptions{}); err != nil {
+		t.Fatalf("CreateEPUB() error = %v", err)
+	}
+	content, _ := readEntries(t, outputPath)
+	if opf := content[epubPackage]; !strings.Contains(opf, `<dc:title>Chapter 5</dc:title>`) || !strings.Contains(opf, `page-progression-direction="ltr"`) {
+		t.Errorf("package document = %s, want title from file name, left to right", opf)
+	}
+	if nav := content[epubNav]; !strings.Contains(nav, `<a href="pages/1.xhtml">Chapter 5</a>`) {
+		t.Errorf("navigation document = %s, want a single entry", nav)
+	}
+
+	// Without Force the existing book is kept
+	if err := CreateEPUB(outputPath, images, EPUBOptions{}); err == nil {
+		t.Error("expected error for existing file without Force")
+	}
+	if err := CreateEPUB(outputPath, images, EPUBOptions{Force: true}); err != nil {
+		t.Errorf("CreateEPUB() with Force error = %v", err)
+	}
+}
+
+func TestCreateEPUB_Deterministic(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{createPNG(t, tmpDir, "a.png", 10, 20), createPNG(t, tmpDir, "b.png", 20, 10)}
+
+	var outputs [][]byte
+	for _, name := range []string{"one.epub", "two.epub"} {
+		outputPath := filepath.Join(tmpDir, name)
+		opts := EPUBOptions{Metadata: &ComicInfo{Title: "Same"}, Deterministic: true}
+		if err := CreateEPUB(outputPath, images, opts); err != nil {
+			t.Fatalf("CreateEPUB() error = %v", err)
+		}
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, data)
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("deterministic books differ")
+	}
+}
+
+func TestCreateEPUB_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+	undecodable := filepath.Join(tmpDir, "bad.png")
+	if err := os.WriteFile(undecodable, []byte("not a png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var bmpData bytes.Buffer
+	if err := bmp.Encode(&bmpData, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	bmpPath := filepath.Join(tmpDir, "page.bmp")
+	if err := os.WriteFile(bmpPath, bmpData.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		images []chapter.ImageFile
+	}{
+		{"no pages", nil},
+		{"undecodable page", []chapter.ImageFile{{Path: undecodable, Name: "bad.png"}}},
+		{"unsupported type", []chapter.ImageFile{{Path: undecodable, Name: "page.tiff"}}},
+		{"BMP is not a core media type", []chapter.ImageFile{{Path: bmpPath, Name: "page.bmp"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, "out.epub")
+			if err := CreateEPUB(outputPath, tt.images, EPUBOptions{}); err == nil {
+				t.Error("expected error")
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Error("no book should be written")
+			}
+			assertNoTempFiles(t, tmpDir)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package cbz
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/sort"
+)
+
+// ExtractOptions configures unpacking archives into chapter folders.
+type ExtractOptions struct {
+	Force      bool     // Replace existing chapter folders if true
+	Extensions []string // Page extensions without dots; empty means chapter.DefaultExtensions
+
+	// RestoreNames gives pages the original names recorded by Renumber
+	// in the archive's ComicInfo.xml notes (see RenameNotes).
+	RestoreNames bool
+}
+
+// FindArchives returns the .cbz and .zip archives named by inputs: each
+// input is an archive, or a directory whose archives are taken (not
+// recursively) in natural order.
+func FindArchives(inputs []string) ([]string, error) {
+	var archives []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !chapter.IsArchive(input) {
+				return nil, fmt.Errorf("not a CBZ or ZIP archive: %s", input)
+			}
+			archives = append(archives, input)
+			continue
+		}
+
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, e := range entries {
+			if !e.IsDir() && chapter.IsArchive(e.Name()) && !strings.HasPrefix(e.Name(), ".") {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Natural(names)
+		for _, name := range names {
+			archives = append(archives, filepath.Join(input, name))
+		}
+	}
+	return archives, nil
+}
+
+// Extract unpacks the pages of the archive at archivePath into a chapter
+// folder named after the archive, in outputDir, which is created if
+// needed. Returns the folder path and the number of pages extracted. An
+// archive without pages is left alone and gives 0 pages, which callers
+// report as skipped, as they do for empty chapters when creating
+// archives.
+//
+// Pages are written in archive order. Only entries with a page
+// extension are extracted; ComicInfo.xml, hidden entries and macOS
+// resource forks are not (see chapter.IsJunkEntry). Pages in folders are
+// extracted by base name, so the chapter folder is flat; it is an error
+// if two pages share a name, or if an entry path is absolute or climbs
+// out of the archive with "..". The archive's central directory is read
+// once for all pages.
+//
+// The folder is filled under a temporary name and renamed into place, as
+// for folder output. Without opts.Force it is an error if it exists.
+func Extract(archivePath, outputDir string, opts ExtractOptions) (string, int, error) {
+	name := filepath.Base(archivePath)
+	folder := filepath.Join(outputDir, strings.TrimSuffix(name, filepath.Ext(name)))
+
+	pages, err := extractPages(archivePath, opts)
+	if err != nil {
+		return folder, 0, err
+	}
+	if len(pages) == 0 {
+		return folder, 0, nil
+	}
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return folder, 0, err
+	}
+	w := &dirArchive{opts: CreateOptions{Force: opts.Force}}
+	if err := w.Begin(folder); err != nil {
+		return folder, 0, err
+	}
+	for _, page := range pages {
+		if err := w.AddPage(page); err != nil {
+			w.Abort()
+			return folder, 0, err
+		}
+	}
+	if err := w.Commit(); err != nil {
+		return folder, 0, err
+	}
+	return folder, len(pages), nil
+}
+
+// extractPages lists the pages of an archive in entry order, named as
+// they will be extracted.
+func extractPages(archivePath string, opts ExtractOptions) ([]chapter.ImageFile, error) {
+	// Pages share one reading of the central directory
+	entries, err := chapter.ArchiveEntries(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = chapter.DefaultExtensions
+	}
+	isImage := extensionSet(extensions)
+
+	var pages []chapter.ImageFile
+	var notes string
+	seen := make(map[string]bool)
+	for _, page := range entries {
+		entry := strings.ReplaceAll(page.Entry, `\`, "/")
+		if !fs.ValidPath(strings.TrimSuffix(entry, "/")) {
+			return nil, fmt.Errorf("unsafe entry path in archive: %s", page.Entry)
+		}
+		if page.Entry == ComicInfoName {
+			if opts.RestoreNames {
+				info, err := readComicInfoEntry(page.Open)
+				if err != nil {
+					return nil, fmt.Errorf("invalid metadata: %v", err)
+				}
+				notes = info.Notes
+			}
+			continue
+		}
+		if chapter.IsJunkEntry(entry) || !isImage[strings.ToLower(strings.TrimPrefix(path.Ext(entry), "."))] {
+			continue
+		}
+
+		name := path.Base(entry)
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("duplicate page name in archive: %s", name)
+		}
+		seen[strings.ToLower(name)] = true
+		page.Name = name
+		pages = append(pages, page)
+	}
+
+	if opts.RestoreNames {
+		restoreNames(pages, ParseRenameNotes(notes))
+	}
+	return pages, nil
+}
+
+// restoreNames renames pages back to their names before renumbering.
+// Names are only restored if every one is a plain file name, unique,
+// and in the same natural order as the pages, so that the chapter reads
+// as before; otherwise the pages keep their names.
+func restoreNames(pages []chapter.ImageFile, renames []Rename) {
+	original := make(map[string]string, len(renames))
+	for _, r := range renames {
+		original[r.To] = r.From
+	}
+
+	names := make([]string, len(pages))
+	seen := make(map[string]bool, len(pages))
+	for i, page := range pages {
+		name, ok := original[page.Name]
+		if !ok || name != path.Base(name) || !fs.ValidPath(name) || strings.Contains(name, `\`) || seen[strings.ToLower(name)] {
+			return
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+
+	sorted := append([]string(nil), names...)
+	sort.Natural(sorted)
+	for i := range names {
+		if sorted[i] != names[i] {
+			return
+		}
+	}
+
+	for i := range pages {
+		pages[i].Name = names[i]
+	}
+}
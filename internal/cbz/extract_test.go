@@ -0,0 +1,252 @@
+package cbz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestExtract_RoundTrip(t *testing.T) {
+	srcDir := filepath.Join(t.TempDir(), "Chapter 1")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"img_1.png", "img_2.png", "img_10.png"} {
+		createPNG(t, srcDir, name, 4, 4)
+	}
+	images, err := chapter.CollectImages(srcDir, []string{"png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a renumbered archive that records the original names
+	renamed, renames := Renumber(images)
+	archiveDir := t.TempDir()
+	archivePath := filepath.Join(archiveDir, "Chapter 1.cbz")
+	meta := &ComicInfo{Title: "Chapter 1", Notes: RenameNotes(renames)}
+	if err := Create(archivePath, renamed, CreateOptions{Metadata: meta}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    ExtractOptions
+		want    []string
+		wantErr bool
+	}{
+		{"archive names", ExtractOptions{}, []string{"1.png", "2.png", "3.png"}, false},
+		{"existing folder", ExtractOptions{}, nil, true},
+		{"restored names", ExtractOptions{Force: true, RestoreNames: true}, []string{"img_1.png", "img_2.png", "img_10.png"}, false},
+	}
+
+	outDir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder, pages, err := Extract(archivePath, outDir, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Extract() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if folder != filepath.Join(outDir, "Chapter 1") {
+				t.Errorf("folder = %s", folder)
+			}
+			if tt.wantErr {
+				return
+			}
+			if pages != len(tt.want) {
+				t.Errorf("pages = %d, want %d", pages, len(tt.want))
+			}
+
+			// The folder is a chapter again, read in the original order
+			got, err := chapter.CollectImages(folder, chapter.DefaultExtensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("folder holds %d pages, want %d", len(got), len(tt.want))
+			}
+			for i, img := range got {
+				if img.Name != tt.want[i] {
+					t.Errorf("page %d = %s, want %s", i, img.Name, tt.want[i])
+				}
+				a, _ := os.ReadFile(img.Path)
+				b, _ := os.ReadFile(images[i].Path)
+				if string(a) != string(b) {
+					t.Errorf("page %d content differs from the original", i)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(folder, ComicInfoName)); !os.IsNotExist(err) {
+				t.Error("metadata should not be extracted")
+			}
+			assertNoTempFiles(t, outDir)
+		})
+	}
+}
+
+func TestExtract_UnsafeArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		wantErr string
+	}{
+		{"parent directory", []zipEntry{{"../evil.jpg", []byte("x")}}, "unsafe entry path"},
+		{"nested parent", []zipEntry{{"a/../../evil.jpg", []byte("x")}}, "unsafe entry path"},
+		{"absolute", []zipEntry{{"/etc/evil.jpg", []byte("x")}}, "unsafe entry path"},
+		{"backslashes", []zipEntry{{`..\evil.jpg`, []byte("x")}}, "unsafe entry path"},
+		{"clashing folders", []zipEntry{{"a/1.jpg", []byte("x")}, {"b/1.jpg", []byte("y")}}, "duplicate page name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath := filepath.Join(tmpDir, "bad.cbz")
+			writeZip(t, archivePath, tt.entries...)
+			outDir := t.TempDir()
+
+			_, _, err := Extract(archivePath, outDir, ExtractOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Extract() error = %v, want %q", err, tt.wantErr)
+			}
+			if entries, _ := os.ReadDir(outDir); len(entries) != 0 {
+				t.Errorf("output dir not empty: %v", entries)
+			}
+		})
+	}
+}
+
+func TestExtract_FlattensAndKeepsOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "ch.cbz")
+	writeZip(t, archivePath,
+		zipEntry{ComicInfoName, []byte("<ComicInfo/>")},
+		zipEntry{"pages/", nil},
+		zipEntry{"pages/b.jpg", []byte("b")},
+		zipEntry{"pages/a.jpg", []byte("a")},
+	)
+
+	folder, pages, err := Extract(archivePath, filepath.Join(tmpDir, "out"), ExtractOptions{RestoreNames: true})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if pages != 2 {
+		t.Errorf("pages = %d, want 2", pages)
+	}
+	for name, want := range map[string]string{"a.jpg": "a", "b.jpg": "b"} {
+		if data, _ := os.ReadFile(filepath.Join(folder, name)); string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestExtract_SkipsJunk(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "ch.cbz")
+	writeZip(t, archivePath,
+		zipEntry{"1.jpg", []byte("1")},
+		zipEntry{"__MACOSX/._1.jpg", []byte("fork")},
+		zipEntry{"._2.jpg", []byte("fork")},
+		zipEntry{".hidden/3.jpg", []byte("hidden")},
+		zipEntry{"Thumbs.db", []byte("cache")},
+		zipEntry{"readme.txt", []byte("notes")},
+		zipEntry{"2.PNG", []byte("2")},
+	)
+
+	folder, pages, err := Extract(archivePath, filepath.Join(tmpDir, "out"), ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	entries, _ := os.ReadDir(folder)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if pages != 2 || strings.Join(names, ",") != "1.jpg,2.PNG" {
+		t.Errorf("extracted %d pages %v, want 1.jpg and 2.PNG", pages, names)
+	}
+
+	// Extensions choose the pages
+	folder, pages, err = Extract(archivePath, filepath.Join(tmpDir, "txt"), ExtractOptions{Extensions: []string{"txt"}})
+	if err != nil || pages != 1 {
+		t.Fatalf("Extract() = %d pages, %v; want 1 page", pages, err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "readme.txt")); err != nil {
+		t.Errorf("page with a chosen extension missing: %v", err)
+	}
+}
+
+func TestExtract_EmptyArchiveSkipped(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "empty.cbz")
+	writeZip(t, archivePath, zipEntry{ComicInfoName, []byte("<ComicInfo/>")})
+
+	folder, pages, err := Extract(archivePath, tmpDir, ExtractOptions{})
+	if err != nil || pages != 0 {
+		t.Errorf("Extract() = %d pages, %v; want 0 pages, nil", pages, err)
+	}
+	if _, err := os.Stat(folder); !os.IsNotExist(err) {
+		t.Error("no folder should be created for an empty archive")
+	}
+}
+
+func TestRestoreNames_KeepsOrder(t *testing.T) {
+	pages := []chapter.ImageFile{{Name: "1.jpg"}, {Name: "2.jpg"}}
+
+	// Restoring would swap the pages under natural sort
+	restoreNames(pages, []Rename{{From: "b.jpg", To: "1.jpg"}, {From: "a.jpg", To: "2.jpg"}})
+	if pages[0].Name != "1.jpg" || pages[1].Name != "2.jpg" {
+		t.Errorf("names = %s, %s; want unchanged", pages[0].Name, pages[1].Name)
+	}
+
+	// Names that are not plain file names are never restored
+	restoreNames(pages, []Rename{{From: "../a.jpg", To: "1.jpg"}, {From: "b.jpg", To: "2.jpg"}})
+	if pages[0].Name != "1.jpg" {
+		t.Errorf("unsafe name restored: %s", pages[0].Name)
+	}
+}
+
+func TestParseRenameNotes(t *testing.T) {
+	renames := []Rename{{"img_1.jpg", "1.jpg"}, {"credits page.png", "2.png"}}
+	notes := "Scanned by someone\n" + RenameNotes(renames) + "\n"
+
+	got := ParseRenameNotes(notes)
+	if len(got) != len(renames) {
+		t.Fatalf("ParseRenameNotes() = %v, want %v", got, renames)
+	}
+	for i := range got {
+		if got[i] != renames[i] {
+			t.Errorf("rename %d = %v, want %v", i, got[i], renames[i])
+		}
+	}
+}
+
+func TestFindArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"Chapter 10.cbz", "Chapter 2.cbz", "Chapter 1.zip", "notes.txt", ".hidden.cbz"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	single := filepath.Join(t.TempDir(), "Single.CBZ")
+	if err := os.WriteFile(single, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FindArchives([]string{tmpDir, single})
+	if err != nil {
+		t.Fatalf("FindArchives() error = %v", err)
+	}
+	want := []string{"Chapter 1.zip", "Chapter 2.cbz", "Chapter 10.cbz", "Single.CBZ"}
+	if len(got) != len(want) {
+		t.Fatalf("FindArchives() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if filepath.Base(got[i]) != want[i] {
+			t.Errorf("archive %d = %s, want %s", i, filepath.Base(got[i]), want[i])
+		}
+	}
+
+	if _, err := FindArchives([]string{filepath.Join(tmpDir, "notes.txt")}); err == nil {
+		t.Error("expected error for a file that is not an archive")
+	}
+}
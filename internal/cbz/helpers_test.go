@@ -0,0 +1,123 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// pngBytes encodes a w x h image with varied pixels, so that truncating
+// the encoding cuts into the image data.
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 31)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// jpegBytes encodes a blank grayscale w x h JPEG.
+func jpegBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatalf("failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeImage writes data to dir/name and returns its ImageFile.
+func writeImage(t *testing.T, dir, name string, data []byte) chapter.ImageFile {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write image %s: %v", path, err)
+	}
+	return chapter.ImageFile{Path: path, Name: name}
+}
+
+// createPNG writes a PNG of the given size and returns its ImageFile.
+func createPNG(t *testing.T, dir, name string, width, height int) chapter.ImageFile {
+	t.Helper()
+	return writeImage(t, dir, name, pngBytes(t, width, height))
+}
+
+// createJPEG writes a blank grayscale JPEG and returns its ImageFile.
+func createJPEG(t *testing.T, dir, name string, width, height int) chapter.ImageFile {
+	t.Helper()
+	return writeImage(t, dir, name, jpegBytes(t, width, height))
+}
+
+// zipEntry is a named entry for writeZip.
+type zipEntry struct {
+	name string
+	data []byte
+}
+
+// writeZip writes a stored (uncompressed) ZIP archive with the entries.
+func writeZip(t *testing.T, path string, entries ...zipEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Store})
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %v", e.name, err)
+		}
+		w.Write(e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+}
+
+// readEntries returns the content of every entry of the archive at path,
+// and the entry names in order.
+func readEntries(t *testing.T, path string) (map[string]string, []string) {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer reader.Close()
+
+	content := make(map[string]string)
+	var names []string
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+		content[f.Name] = string(data)
+		names = append(names, f.Name)
+	}
+	return content, names
+}
+
+// entryNames returns the names of the entries of the archive at path.
+func entryNames(t *testing.T, path string) []string {
+	t.Helper()
+	_, names := readEntries(t, path)
+	return names
+}
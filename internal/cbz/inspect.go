@@ -0,0 +1,295 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"manga2cbz/internal/chapter"
+)
+
+// EntryInfo describes one archive entry.
+type EntryInfo struct {
+	Name           string `json:"name"`
+	Size           uint64 `json:"size"`
+	CompressedSize uint64 `json:"compressed_size"`
+	Method         string `json:"method"`           // "store", "deflate", or the method number
+	CRC32          string `json:"crc32"`            // As stored in the archive, in hex
+	Format         string `json:"format,omitempty"` // Image format detected from the content, e.g. "jpeg"
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+}
+
+// Inspection describes the contents of an archive.
+type Inspection struct {
+	Path     string      `json:"path"`
+	Entries  []EntryInfo `json:"entries"`            // In archive order
+	Metadata *ComicInfo  `json:"metadata,omitempty"` // Parsed ComicInfo.xml, if present
+	Problems []Problem   `json:"problems"`
+}
+
+// Aspect ratios (width over height) within this factor of the usual
+// ratio of an archive's pages, or of twice that for double-page spreads,
+// are not flagged.
+const aspectTolerance = 1.3
+
+// Inspect lists the entries of the archive at archivePath in archive
+// order, with the format and dimensions of each image, and reads its
+// ComicInfo.xml. Images are detected from their content; only image
+// headers are read, so unlike Verify, Inspect does not check the data.
+//
+// Problems are flagged without failing: duplicate names, extensions that
+// do not match the image format, more than one image format, pages whose
+// aspect ratio stands out from the rest, pages stored out of natural sort
+// order, unreadable images and invalid metadata.
+func Inspect(archivePath string) (Inspection, error) {
+	in := Inspection{Path: archivePath, Entries: []EntryInfo{}, Problems: []Problem{}}
+	add := func(entry, format string, args ...interface{}) {
+		in.Problems = append(in.Problems, Problem{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return in, err
+	}
+	defer reader.Close()
+
+	isImage := extensionSet(chapter.DefaultExtensions)
+	count := make(map[string]int)
+	formats := make(map[string]int)
+	var pages []EntryInfo
+	for _, f := range reader.File {
+		entry := EntryInfo{
+			Name:           f.Name,
+			Size:           f.UncompressedSize64,
+			CompressedSize: f.CompressedSize64,
+			Method:         methodName(f.Method),
+			CRC32:          fmt.Sprintf("%08x", f.CRC32),
+		}
+		count[f.Name]++
+
+		switch {
+		case f.FileInfo().IsDir():
+		case f.Name == ComicInfoName:
+			info, err := readComicInfoEntry(f.Open)
+			if err != nil {
+				add(f.Name, "invalid metadata: %v", err)
+			} else {
+				in.Metadata = &info
+			}
+		default:
+			cfg, format, err := entryConfig(f)
+			if err == nil {
+				entry.Format, entry.Width, entry.Height = format, cfg.Width, cfg.Height
+				formats[format]++
+				pages = append(pages, entry)
+				if want := extensionFormat(f.Name); want != "" && want != format {
+					add(f.Name, "%s image with a %s extension", format, path.Ext(f.Name))
+				}
+			} else if isImage[strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))] {
+				add(f.Name, "cannot read image: %v", err)
+			}
+		}
+		in.Entries = append(in.Entries, entry)
+	}
+
+	// Duplicates, once per name in archive order
+	for _, entry := range in.Entries {
+		if n := count[entry.Name]; n > 1 {
+			add(entry.Name, "stored %d times", n)
+			count[entry.Name] = 0
+		}
+	}
+
+	if len(formats) > 1 {
+		add("", "mixed image formats: %s", formatCounts(formats))
+	}
+
+	for _, page := range oddAspects(pages) {
+		add(page.Name, "unusual aspect ratio %dx%d", page.Width, page.Height)
+	}
+
+	names := make([]string, len(pages))
+	for i, page := range pages {
+		names[i] = page.Name
+	}
+	if problem, ok := orderProblem(names); ok {
+		in.Problems = append(in.Problems, problem)
+	}
+
+	return in, nil
+}
+
+// entryConfig decodes the image header of an archive entry.
+func entryConfig(f *zip.File) (image.Config, string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer rc.Close()
+	return image.DecodeConfig(rc)
+}
+
+// methodName names a ZIP compression method.
+func methodName(method uint16) string {
+	switch method {
+	case zip.Store:
+		return "store"
+	case zip.Deflate:
+		return "deflate"
+	}
+	return strconv.Itoa(int(method))
+}
+
+// extensionFormat returns the image format name image.DecodeConfig
+// reports for files with name's extension, or "" if it is not an image
+// extension.
+func extensionFormat(name string) string {
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png", ".gif", ".bmp", ".webp":
+		return ext[1:]
+	}
+	return ""
+}
+
+// formatCounts formats image format counts as "jpeg (10), png (2)",
+// most common first.
+func formatCounts(formats map[string]int) string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if formats[names[i]] != formats[names[j]] {
+			return formats[names[i]] > formats[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%d)", name, formats[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// oddAspects returns the pages whose aspect ratio is not within
+// aspectTolerance of the median page's, or of a spread of two such pages.
+// Archives with fewer than three pages have no usual ratio to compare.
+func oddAspects(pages []EntryInfo) []EntryInfo {
+	if len(pages) < 3 {
+		return nil
+	}
+
+	ratios := make([]float64, len(pages))
+	for i, page := range pages {
+		ratios[i] = float64(page.Width) / float64(max(page.Height, 1))
+	}
+	sorted := append([]float64(nil), ratios...)
+	sort.Float64s(sorted)
+	usual := sorted[len(sorted)/2]
+
+	near := func(ratio, want float64) bool {
+		return ratio <= want*aspectTolerance && ratio >= want/aspectTolerance
+	}
+
+	var odd []EntryInfo
+	for i, page := range pages {
+		if !near(ratios[i], usual) && !near(ratios[i], 2*usual) {
+			odd = append(odd, page)
+		}
+	}
+	return odd
+}
+
+// WriteJSON writes the inspection as indented JSON.
+func (in Inspection) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(in)
+}
+
+// WriteTable writes the inspection for the console: a table of entries,
+// the metadata fields that are set, then the problems found.
+func (in Inspection) WriteTable(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s: %d entries\n", in.Path, len(in.Entries))
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tName\tSize\tStored\tMethod\tCRC32\tFormat\tDimensions")
+	for i, e := range in.Entries {
+		dimensions := ""
+		if e.Format != "" {
+			dimensions = fmt.Sprintf("%dx%d", e.Width, e.Height)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			i+1, e.Name, e.Size, e.CompressedSize, e.Method, e.CRC32, e.Format, dimensions)
+	}
+	tw.Flush()
+
+	if in.Metadata != nil {
+		fmt.Fprintln(&b, "Metadata:")
+		for _, field := range in.Metadata.fields() {
+			fmt.Fprintf(&b, "  %s: %s\n", field[0], field[1])
+		}
+	}
+
+	if len(in.Problems) == 0 {
+		fmt.Fprintln(&b, "No problems found")
+	} else {
+		fmt.Fprintln(&b, "Problems:")
+		for _, p := range in.Problems {
+			if p.Entry == "" {
+				fmt.Fprintf(&b, "  %s\n", p.Message)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s\n", p.Entry, p.Message)
+			}
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// fields returns the name and value of each field that is set, in
+// schema order. Pages are summarized by count and bookmarks.
+func (info ComicInfo) fields() [][2]string {
+	var fields [][2]string
+	addField := func(name, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{name, value})
+		}
+	}
+	// Zero means unset for counts, unlike a chapter Number of "0"
+	addInt := func(name string, value int) {
+		if value != 0 {
+			addField(name, strconv.Itoa(value))
+		}
+	}
+	addField("Title", info.Title)
+	addField("Series", info.Series)
+	addField("Number", info.Number)
+	addInt("Volume", info.Volume)
+	addField("Notes", strings.ReplaceAll(info.Notes, "\n", "; "))
+	addField("Writer", info.Writer)
+	addInt("PageCount", info.PageCount)
+	addField("LanguageISO", info.LanguageISO)
+	addField("Manga", info.Manga)
+	addInt("Pages", len(info.Pages))
+	for _, p := range info.Pages {
+		if p.Bookmark != "" {
+			addField(fmt.Sprintf("Bookmark (page %d)", p.Image+1), p.Bookmark)
+		}
+	}
+	return fields
+}
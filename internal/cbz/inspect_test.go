@@ -0,0 +1,154 @@
+package cbz
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInspect_SoundArchive(t *testing.T) {
+	tmpDir, images := createTestImages(t, 0)
+	images = append(images,
+		createPNG(t, tmpDir, "page1.png", 60, 90),
+		createPNG(t, tmpDir, "page2.png", 120, 90), // Spread
+		createPNG(t, tmpDir, "page10.png", 62, 90),
+	)
+	archivePath := filepath.Join(tmpDir, "out.cbz")
+	meta := &ComicInfo{Title: "Chapter 1", Series: "Series", Pages: PageList{{Image: 0, Bookmark: "Start"}}}
+	if err := Create(archivePath, images, CreateOptions{Metadata: meta}); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := Inspect(archivePath)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if len(in.Problems) != 0 {
+		t.Errorf("Problems = %v, want none", in.Problems)
+	}
+	if len(in.Entries) != 4 || in.Entries[0].Name != ComicInfoName || in.Entries[0].Method != "deflate" {
+		t.Fatalf("Entries = %+v", in.Entries)
+	}
+	page := in.Entries[2]
+	if page.Name != "page2.png" || page.Format != "png" || page.Width != 120 || page.Height != 90 || page.Method != "store" || len(page.CRC32) != 8 {
+		t.Errorf("page entry = %+v", page)
+	}
+	if in.Metadata == nil || in.Metadata.Title != "Chapter 1" {
+		t.Errorf("Metadata = %+v", in.Metadata)
+	}
+
+	var table bytes.Buffer
+	if err := in.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"4 entries", "page10.png", "120x90", "Title: Chapter 1", "Bookmark (page 1): Start", "No problems found"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table missing %q:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := in.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Inspection
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.Entries) != 4 || decoded.Entries[3].Width != 62 || decoded.Metadata.Series != "Series" {
+		t.Errorf("decoded JSON = %+v", decoded)
+	}
+	if strings.Contains(out.String(), "XMLName") {
+		t.Error("JSON output includes the XML element name")
+	}
+
+	// Every key is snake_case, metadata included
+	if keys := regexp.MustCompile(`"[a-z0-9_]*[A-Z][A-Za-z0-9_]*":`).FindAllString(out.String(), -1); len(keys) != 0 {
+		t.Errorf("JSON keys not in snake_case: %v", keys)
+	}
+	for _, want := range []string{`"compressed_size":`, `"page_count":`, `"image": 0`, `"image_width":`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("JSON missing %s:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestComicInfo_Fields(t *testing.T) {
+	info := ComicInfo{Series: "S", Number: "0", Volume: 0, PageCount: 0}
+	var got []string
+	for _, f := range info.fields() {
+		got = append(got, f[0]+"="+f[1])
+	}
+	// Chapter 0 is a real number; zero counts are unset
+	if want := "Series=S,Number=0"; strings.Join(got, ",") != want {
+		t.Errorf("fields() = %v, want %s", got, want)
+	}
+}
+
+func TestInspect_Problems(t *testing.T) {
+	tmpDir := t.TempDir()
+	archivePath := filepath.Join(tmpDir, "bad.cbz")
+	writeZip(t, archivePath,
+		zipEntry{"page2.png", pngBytes(t, 60, 90)},
+		zipEntry{"page1.png", pngBytes(t, 60, 90)},
+		zipEntry{"page3.jpg", jpegBytes(t, 60, 90)},
+		zipEntry{"page4.png", jpegBytes(t, 60, 90)},
+		zipEntry{"page5.png", pngBytes(t, 60, 600)},
+		zipEntry{"page5.png", pngBytes(t, 60, 90)},
+		zipEntry{"page6.jpg", []byte("garbage")},
+		zipEntry{ComicInfoName, []byte("<ComicInfo><Title>")},
+	)
+
+	in, err := Inspect(archivePath)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+
+	tests := []struct {
+		entry string
+		want  string
+	}{
+		{"page1.png", ""},
+		{"page2.png", "out of natural sort order"},
+		{"page4.png", "jpeg image with a .png extension"},
+		{"page5.png", "stored 2 times"},
+		{"page6.jpg", "cannot read image"},
+		{ComicInfoName, "invalid metadata"},
+		{"", "mixed image formats: png (4), jpeg (2)"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range in.Problems {
+			if p.Entry == tt.entry {
+				got = append(got, p.Message)
+			}
+		}
+		joined := strings.Join(got, "; ")
+		if tt.want == "" && joined != "" || !strings.Contains(joined, tt.want) {
+			t.Errorf("problems for %q = %q, want %q", tt.entry, joined, tt.want)
+		}
+	}
+
+	found := false
+	for _, p := range in.Problems {
+		found = found || p.Entry == "page5.png" && strings.Contains(p.Message, "unusual aspect ratio 60x600")
+	}
+	if !found {
+		t.Errorf("tall page not flagged: %v", in.Problems)
+	}
+
+	var table bytes.Buffer
+	in.WriteTable(&table)
+	if !strings.Contains(table.String(), "Problems:\n") {
+		t.Errorf("table does not list problems:\n%s", table.String())
+	}
+}
+
+func TestInspect_NotAnArchive(t *testing.T) {
+	if _, err := Inspect(filepath.Join(t.TempDir(), "missing.cbz")); err == nil {
+		t.Error("expected error for missing archive")
+	}
+}
@@ -0,0 +1,324 @@
+package cbz
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+)
+
+// Bytes of ZIP structure per entry besides its data and its name (which
+// is stored twice): local file header, data descriptor and central
+// directory header, with room for timestamp and Zip64 extra fields.
+const entryOverhead = 30 + 24 + 46 + 2*(9+28)
+
+// Bytes of ZIP structure per archive besides its comment: the end of
+// central directory record, plus the Zip64 record and locator.
+const archiveOverhead = 22 + 56 + 20
+
+// partMarker starts the last line of a part's ZIP comment, which records
+// the part's number and the number of parts: "manga2cbz-part 2/3". Only
+// archives carrying it are treated as parts, so an unrelated archive
+// whose name merely looks like a part (another chapter's output, say)
+// is never counted or removed.
+const partMarker = "manga2cbz-part "
+
+// part is a run of consecutive images written as one archive.
+type part struct {
+	offset int // Index of the first image in the full list
+	images []chapter.ImageFile
+}
+
+// CreateParts creates the archive for images at outputPath, as Create
+// does, and returns the paths written.
+//
+// If opts.MaxSize or opts.MaxPages would be exceeded, the images are
+// split at page boundaries into parts named "name (Part 1).cbz",
+// "name (Part 2).cbz" and so on, which sort naturally. A single page
+// larger than MaxSize gets a part of its own. Parts are planned before
+// anything is written, so the same input always gives the same parts.
+// Pages produced by a Source are encoded once just to measure them when
+// MaxSize is set. Each part gets its own ComicInfo.xml, with the
+// caller's page entries renumbered to match, and a ZIP comment that
+// marks it as part n of the split after opts.Comment (see ReadComment).
+//
+// Without opts.Force it is an error if the archive, or part 1 of a split
+// archive, already exists. With Force, parts left by an earlier run with
+// a different split are removed, so only the new set remains.
+func CreateParts(outputPath string, images []chapter.ImageFile, opts CreateOptions) ([]string, error) {
+	for _, path := range []string{outputPath, PartPath(outputPath, 1)} {
+		if err := checkExisting(path, opts.Force); err != nil {
+			return nil, err
+		}
+	}
+
+	parts, err := planParts(images, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{outputPath}
+	if len(parts) > 1 {
+		paths = make([]string, len(parts))
+		for i := range parts {
+			paths[i] = PartPath(outputPath, i+1)
+		}
+	}
+
+	// Write every part before making any of them visible
+	temps := make([]string, 0, len(parts))
+	defer func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}()
+	for i, p := range parts {
+		// Existing outputs were checked above
+		w := &zipArchive{opts: opts}
+		w.opts.Force = true
+		if len(parts) > 1 {
+			w.opts.Comment = partComment(opts.Comment, i+1, len(parts))
+		}
+		metadata := opts.Metadata
+		if metadata != nil {
+			metadata = metadata.forPart(p)
+		}
+
+		if err := w.Begin(paths[i]); err != nil {
+			return nil, err
+		}
+		if err := addPages(w, p.images, metadata); err != nil {
+			w.Abort()
+			return nil, err
+		}
+		temp, err := w.finish()
+		if err != nil {
+			return nil, err
+		}
+		temps = append(temps, temp)
+	}
+
+	for i := range paths {
+		if err := os.Rename(temps[i], paths[i]); err != nil {
+			return nil, err
+		}
+	}
+	temps = nil
+
+	// Persist the renames; not supported on every platform
+	dir, _ := splitPath(outputPath)
+	syncDir(dir)
+
+	if opts.Force {
+		removeStaleParts(outputPath, len(paths))
+	}
+	return paths, nil
+}
+
+// planParts splits images into parts within the size and page limits.
+func planParts(images []chapter.ImageFile, opts CreateOptions) ([]part, error) {
+	if opts.MaxSize <= 0 && opts.MaxPages <= 0 {
+		return []part{{images: images}}, nil
+	}
+
+	// Space taken in every part by the archive structure, comment and
+	// metadata; the full page list is an upper bound for any part's
+	// ComicInfo.xml, and its length for the part numbers in the comment
+	reserved := int64(archiveOverhead + len(partComment(opts.Comment, len(images), len(images))))
+	if opts.Metadata != nil {
+		data, err := MarshalComicInfo(opts.Metadata.withPages(images))
+		if err != nil {
+			return nil, err
+		}
+		reserved += int64(len(data) + entryOverhead + 2*len(ComicInfoName))
+	}
+
+	parts := []part{{}}
+	used := reserved
+	for i, img := range images {
+		size := int64(0)
+		if opts.MaxSize > 0 {
+			var err error
+			if size, err = pageSize(img); err != nil {
+				return nil, err
+			}
+			size += int64(entryOverhead + 2*len(img.Name))
+		}
+
+		current := &parts[len(parts)-1]
+		full := opts.MaxPages > 0 && len(current.images) >= opts.MaxPages
+		tooBig := opts.MaxSize > 0 && used+size > opts.MaxSize
+		if len(current.images) > 0 && (full || tooBig) {
+			parts = append(parts, part{offset: i})
+			current = &parts[len(parts)-1]
+			used = reserved
+		}
+		current.images = append(current.images, img)
+		used += size
+	}
+	return parts, nil
+}
+
+// pageSize returns the number of bytes an image takes in the archive.
+func pageSize(img chapter.ImageFile) (int64, error) {
+	if img.Source == nil {
+		info, err := img.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	var counter countingWriter
+	if err := img.Source.Encode(&counter); err != nil {
+		return 0, err
+	}
+	return int64(counter), nil
+}
+
+// countingWriter discards what is written and counts the bytes.
+type countingWriter int64
+
+// Write implements io.Writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+// forPart returns a copy of info for one part of a split archive, keeping
+// the caller's page entries that fall in the part, renumbered from zero.
+func (info *ComicInfo) forPart(p part) *ComicInfo {
+	c := *info
+	c.Pages = nil
+	for _, page := range info.Pages {
+		if page.Image >= p.offset && page.Image < p.offset+len(p.images) {
+			page.Image -= p.offset
+			c.Pages = append(c.Pages, page)
+		}
+	}
+	return &c
+}
+
+// PartPath returns the path of part n of the archive at outputPath:
+// "name (Part n).ext".
+func PartPath(outputPath string, n int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s (Part %d)%s", strings.TrimSuffix(outputPath, ext), n, ext)
+}
+
+// Parts returns the archives written for outputPath: the unsplit
+// archive if it exists, then every part of the split recorded in part 1,
+// in order. Parts that have since gone missing are still listed, so that
+// reading them fails instead of a broken set passing as complete. Both
+// kinds are returned if both exist, as after an interrupted run.
+func Parts(outputPath string) []string {
+	var paths []string
+	if _, err := os.Stat(outputPath); err == nil {
+		paths = append(paths, outputPath)
+	}
+	if first, count, ok := readPart(PartPath(outputPath, 1)); ok && first == 1 {
+		for n := 1; n <= count; n++ {
+			paths = append(paths, PartPath(outputPath, n))
+		}
+	}
+	return paths
+}
+
+// ReadComment returns the ZIP comment of the archive at path, without
+// the line CreateParts adds to mark parts of a split archive.
+func ReadComment(path string) (string, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	comment, _, _, _ := splitComment(reader.Comment)
+	return comment, nil
+}
+
+// partComment returns the ZIP comment for part n of count: comment,
+// followed by the part marker on a line of its own.
+func partComment(comment string, n, count int) string {
+	marker := fmt.Sprintf("%s%d/%d", partMarker, n, count)
+	if comment == "" {
+		return marker
+	}
+	return comment + "\n" + marker
+}
+
+// splitComment separates a ZIP comment written by partComment into the
+// caller's comment and the part number and count. ok is false if the
+// comment has no part marker, in which case it is returned whole.
+func splitComment(full string) (comment string, n, count int, ok bool) {
+	comment, marker := "", full
+	if i := strings.LastIndexByte(full, '\n'); i >= 0 {
+		comment, marker = full[:i], full[i+1:]
+	}
+	rest, found := strings.CutPrefix(marker, partMarker)
+	number, total, slash := strings.Cut(rest, "/")
+	if !found || !slash || !isDigits(number) || !isDigits(total) {
+		return full, 0, 0, false
+	}
+	n, _ = strconv.Atoi(number)
+	count, _ = strconv.Atoi(total)
+	return comment, n, count, n >= 1 && n <= count
+}
+
+// readPart returns the part number and count recorded in the archive at
+// path. ok is false if it is missing, unreadable or not a part.
+func readPart(path string) (n, count int, ok bool) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer reader.Close()
+
+	_, n, count, ok = splitComment(reader.Comment)
+	return n, count, ok
+}
+
+// removeStaleParts removes archives for outputPath that a split into
+// count parts did not write: parts numbered above count, parts of any
+// number if count is 1, and the unsplit archive if count is above 1.
+// Only archives marked as the part their name says are removed.
+// Errors are ignored; a leftover file is only clutter.
+func removeStaleParts(outputPath string, count int) {
+	dir, name := splitPath(outputPath)
+
+	if count > 1 {
+		os.Remove(outputPath)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		n, ok := partNumber(e.Name(), name)
+		if !ok || (count > 1 && n <= count) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if marked, _, ok := readPart(path); ok && marked == n {
+			os.Remove(path)
+		}
+	}
+}
+
+// partNumber returns n if name is the name PartPath gives part n of an
+// archive named outputName.
+func partNumber(name, outputName string) (int, bool) {
+	ext := filepath.Ext(outputName)
+	rest, ok := strings.CutPrefix(name, strings.TrimSuffix(outputName, ext)+" (Part ")
+	if !ok {
+		return 0, false
+	}
+	number, ok := strings.CutSuffix(rest, ")"+ext)
+	if !ok || !isDigits(number) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(number)
+	return n, err == nil
+}
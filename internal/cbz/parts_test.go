@@ -0,0 +1,262 @@
+package cbz
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/sort"
+)
+
+// createSizedImages writes count page files of size bytes each.
+func createSizedImages(t *testing.T, dir string, count, size int) []chapter.ImageFile {
+	t.Helper()
+	images := make([]chapter.ImageFile, count)
+	for i := range images {
+		name := string(rune('a'+i)) + ".jpg"
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, bytes.Repeat([]byte{byte(i)}, size), 0644); err != nil {
+			t.Fatalf("failed to create page: %v", err)
+		}
+		images[i] = chapter.ImageFile{Path: path, Name: name}
+	}
+	return images
+}
+
+func TestCreateParts_NoLimitsKeepsName(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := createSizedImages(t, tmpDir, 3, 100)
+	outputPath := filepath.Join(tmpDir, "Chapter 1.cbz")
+
+	paths, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 3})
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	if len(paths) != 1 || paths[0] != outputPath {
+		t.Errorf("paths = %v, want [%s]", paths, outputPath)
+	}
+}
+
+func TestCreateParts_MaxPages(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := createSizedImages(t, tmpDir, 5, 100)
+	outputPath := filepath.Join(tmpDir, "Vol 1.cbz")
+
+	paths, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2})
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+
+	want := []string{"Vol 1 (Part 1).cbz", "Vol 1 (Part 2).cbz", "Vol 1 (Part 3).cbz"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	wantEntries := [][]string{{"a.jpg", "b.jpg"}, {"c.jpg", "d.jpg"}, {"e.jpg"}}
+	for i, path := range paths {
+		if filepath.Base(path) != want[i] {
+			t.Errorf("paths[%d] = %s, want %s", i, filepath.Base(path), want[i])
+		}
+		if got := entryNames(t, path); strings.Join(got, ",") != strings.Join(wantEntries[i], ",") {
+			t.Errorf("part %d entries = %v, want %v", i+1, got, wantEntries[i])
+		}
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("unsplit archive should not be written")
+	}
+}
+
+func TestCreateParts_MaxSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := createSizedImages(t, tmpDir, 6, 10000)
+	images = append(images, createSizedImages(t, t.TempDir(), 1, 50000)[0])
+	images[6].Name = "z.jpg"
+	outputPath := filepath.Join(tmpDir, "big.cbz")
+
+	const maxSize = 25000
+	opts := CreateOptions{MaxSize: maxSize, Metadata: &ComicInfo{Title: "Big"}, Comment: "fingerprint"}
+	paths, err := CreateParts(outputPath, images, opts)
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+
+	// Two 10000-byte pages fit with metadata; the oversized page goes alone
+	if len(paths) != 4 {
+		t.Fatalf("got %d parts, want 4: %v", len(paths), paths)
+	}
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("part %d missing: %v", i+1, err)
+		}
+		if i < 3 && info.Size() > maxSize {
+			t.Errorf("part %d is %d bytes, over the %d limit", i+1, info.Size(), maxSize)
+		}
+		meta, names := readComicInfo(t, path)
+		if meta.PageCount != len(names)-1 {
+			t.Errorf("part %d PageCount = %d, want %d", i+1, meta.PageCount, len(names)-1)
+		}
+	}
+	if names := entryNames(t, paths[3]); len(names) != 2 || names[1] != "z.jpg" {
+		t.Errorf("last part entries = %v, want metadata and z.jpg", names)
+	}
+
+	// Part names sort naturally
+	sorted := append([]string(nil), paths...)
+	sort.Natural(sorted)
+	for i := range paths {
+		if sorted[i] != paths[i] {
+			t.Errorf("natural sort = %v, want %v", sorted, paths)
+			break
+		}
+	}
+}
+
+func TestCreateParts_RenumbersPageMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := createSizedImages(t, tmpDir, 4, 10)
+	outputPath := filepath.Join(tmpDir, "merged.cbz")
+
+	meta := &ComicInfo{Pages: PageList{
+		{Image: 0, Bookmark: "Chapter 1"},
+		{Image: 3, Bookmark: "Chapter 2"},
+	}}
+	paths, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2, Metadata: meta})
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+
+	second, _ := readComicInfo(t, paths[1])
+	if second.Pages[1].Bookmark != "Chapter 2" || second.Pages[0].Bookmark != "" {
+		t.Errorf("part 2 pages = %+v, want Chapter 2 bookmarked on its second page", second.Pages)
+	}
+	if len(meta.Pages) != 2 || meta.Pages[1].Image != 3 {
+		t.Errorf("caller metadata was modified: %+v", meta.Pages)
+	}
+}
+
+func TestCreateParts_ExistingAndStaleParts(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := createSizedImages(t, tmpDir, 6, 10)
+	outputPath := filepath.Join(tmpDir, "ch.cbz")
+
+	if _, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2}); err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+
+	// A split archive counts as existing
+	if _, err := CreateParts(outputPath, images, CreateOptions{}); err == nil {
+		t.Fatal("expected error for existing parts without Force")
+	}
+
+	// Rebuilding with fewer parts removes the leftovers
+	paths, err := CreateParts(outputPath, images, CreateOptions{Force: true, MaxPages: 3})
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("paths = %v, want 2 parts", paths)
+	}
+	if _, err := os.Stat(PartPath(outputPath, 3)); !os.IsNotExist(err) {
+		t.Error("stale part 3 was not removed")
+	}
+
+	// Rebuilding unsplit removes every part
+	if _, err := CreateParts(outputPath, images, CreateOptions{Force: true}); err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "(Part") {
+			t.Errorf("stale part %s was not removed", e.Name())
+		}
+	}
+
+	// Another output that is only named like a part is left alone
+	other := PartPath(outputPath, 2)
+	if err := Create(other, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := CreateParts(outputPath, images, CreateOptions{Force: true}); err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated archive %s was removed", filepath.Base(other))
+	}
+}
+
+func TestCreateParts_FailureWritesNothing(t *testing.T) {
+	tmpDir, images := createTestImages(t, 3)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+	images[2].Source = stringSource{err: os.ErrInvalid}
+
+	if _, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2}); err == nil {
+		t.Fatal("expected error from failing source")
+	}
+
+	// Part 1 was complete, but must not appear without part 2
+	if _, err := os.Stat(PartPath(outputPath, 1)); !os.IsNotExist(err) {
+		t.Error("part 1 was made visible although part 2 failed")
+	}
+	assertNoTempFiles(t, tmpDir)
+}
+
+func TestParts(t *testing.T) {
+	tmpDir, images := createTestImages(t, 5)
+	outputPath := filepath.Join(tmpDir, "Vol 1.cbz")
+
+	if got := Parts(outputPath); len(got) != 0 {
+		t.Errorf("Parts() = %v, want none", got)
+	}
+
+	// Every part of the recorded split is listed, even one gone missing
+	want, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2})
+	if err != nil || len(want) != 3 {
+		t.Fatalf("CreateParts() = %v, %v; want 3 parts", want, err)
+	}
+	if err := os.Remove(want[2]); err != nil {
+		t.Fatal(err)
+	}
+	if got := Parts(outputPath); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Parts() = %v, want %v", got, want)
+	}
+
+	// A leftover unsplit archive comes first
+	leftover := filepath.Join(t.TempDir(), "Vol 1.cbz")
+	if err := Create(leftover, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := os.Rename(leftover, outputPath); err != nil {
+		t.Fatal(err)
+	}
+	want = append([]string{outputPath}, want...)
+	if got := Parts(outputPath); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Parts() = %v, want %v", got, want)
+	}
+
+	// An archive that is only named like a part is another output
+	other := filepath.Join(tmpDir, "Vol 2.cbz")
+	if err := Create(PartPath(other, 1), images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := Parts(other); len(got) != 0 {
+		t.Errorf("Parts() = %v, want none", got)
+	}
+}
+
+func TestReadComment(t *testing.T) {
+	tmpDir, images := createTestImages(t, 3)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	paths, err := CreateParts(outputPath, images, CreateOptions{MaxPages: 2, Comment: "note"})
+	if err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	for _, path := range paths {
+		if comment, err := ReadComment(path); err != nil || comment != "note" {
+			t.Errorf("ReadComment(%s) = %q, %v; want %q", filepath.Base(path), comment, err, "note")
+		}
+	}
+}
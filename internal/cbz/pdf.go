@@ -0,0 +1,392 @@
+package cbz
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"manga2cbz/internal/chapter"
+)
+
+// PDFOptions configures PDF document creation.
+type PDFOptions struct {
+	Force bool // Overwrite existing files if true
+
+	// Metadata supplies the document title and author, chosen as for
+	// EPUBOptions. Bookmarked pages become the document outline. May be nil.
+	Metadata *ComicInfo
+
+	Deterministic bool // Omit the creation date, so output depends on the inputs only
+}
+
+// Object numbers of the document catalog and page tree, which are
+// referenced by every page before they are written at the end.
+const (
+	pdfCatalog = 1
+	pdfPages   = 2
+)
+
+// CreatePDF creates a PDF document at outputPath with one page per image,
+// in order, each page the size of its image at one point per pixel.
+// JPEG pages are embedded as they are (DCTDecode); other pages are
+// decoded and embedded losslessly as Flate-compressed images, with any
+// transparency kept as a soft mask. Bookmarked pages in opts.Metadata,
+// such as the chapter starts of merged volumes, become outline entries.
+//
+// Pages are written one at a time as they are read, so memory use does
+// not grow with the page count. The document is written atomically, as
+// Create writes archives. It is an error if images is empty.
+func CreatePDF(outputPath string, images []chapter.ImageFile, opts PDFOptions) error {
+	if err := checkExisting(outputPath, opts.Force); err != nil {
+		return err
+	}
+
+	if len(images) == 0 {
+		return errors.New("no pages for PDF: " + outputPath)
+	}
+
+	temp, err := writeTemp(outputPath, func(w io.Writer) error {
+		return writePDF(w, images, epubTitle(outputPath, opts.Metadata), opts)
+	})
+	if err != nil {
+		return err
+	}
+	return commitTemp(temp, outputPath)
+}
+
+// pdfWriter writes numbered PDF objects, recording their offsets for the
+// cross-reference table. The first write error is kept and returned by
+// later writes, so callers check it once per object.
+type pdfWriter struct {
+	w       *bufio.Writer
+	n       int64   // Bytes written so far
+	offsets []int64 // Offset of object i+1; zero until written
+	err     error
+}
+
+// Write implements io.Writer.
+func (p *pdfWriter) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.err = err
+	return n, err
+}
+
+// printf writes formatted output; errors are kept in p.err.
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(p, format, args...)
+}
+
+// alloc reserves the next object number.
+func (p *pdfWriter) alloc() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets)
+}
+
+// object starts object num; the caller writes its body and "endobj".
+func (p *pdfWriter) object(num int) {
+	p.offsets[num-1] = p.n
+	p.printf("%d 0 obj\n", num)
+}
+
+// dict writes object num holding a single dictionary or other value.
+func (p *pdfWriter) dict(num int, body string) {
+	p.object(num)
+	p.printf("%s\nendobj\n", body)
+}
+
+// stream writes object num as a stream with the given dictionary
+// entries, filled by fill. The length is not known in advance, so it is
+// written as a separate object afterwards.
+func (p *pdfWriter) stream(num int, entries string, fill func(io.Writer) error) error {
+	length := p.alloc()
+	p.object(num)
+	p.printf("<< %s /Length %d 0 R >>\nstream\n", entries, length)
+	start := p.n
+	if err := fill(p); err != nil {
+		return err
+	}
+	size := p.n - start
+	p.printf("\nendstream\nendobj\n")
+	p.dict(length, fmt.Sprint(size))
+	return p.err
+}
+
+// writePDF writes the PDF document with a page per image to w.
+func writePDF(w io.Writer, images []chapter.ImageFile, title string, opts PDFOptions) error {
+	p := &pdfWriter{w: bufio.NewWriter(w)}
+	p.alloc() // Catalog
+	p.alloc() // Page tree
+
+	// Binary comment marks the file as binary for transfer tools
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	pages := make([]int, len(images))
+	for i, img := range images {
+		num, err := p.page(img)
+		if err != nil {
+			return fmt.Errorf("%s: %v", img.Name, err)
+		}
+		pages[i] = num
+	}
+
+	// Page tree
+	kids := make([]string, len(pages))
+	for i, num := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", num)
+	}
+	p.dict(pdfPages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+
+	// Catalog, with the outline shown if there is one
+	catalog := fmt.Sprintf("/Type /Catalog /Pages %d 0 R", pdfPages)
+	if outline := p.outline(opts.Metadata, pages); outline != 0 {
+		catalog += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outline)
+	}
+	p.dict(pdfCatalog, "<< "+catalog+" >>")
+
+	// Document information
+	info := "/Producer (manga2cbz) /Title " + pdfString(title)
+	if opts.Metadata != nil && opts.Metadata.Writer != "" {
+		info += " /Author " + pdfString(opts.Metadata.Writer)
+	}
+	if !opts.Deterministic {
+		info += " /CreationDate " + pdfString(time.Now().UTC().Format("D:20060102150405Z"))
+	}
+	infoNum := p.alloc()
+	p.dict(infoNum, "<< "+info+" >>")
+
+	// Cross-reference table and trailer
+	xref := p.n
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, pdfCatalog, infoNum, xref)
+
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+// page writes the image and a page showing it at full size. Returns the
+// page's object number.
+func (p *pdfWriter) page(img chapter.ImageFile) (int, error) {
+	xobject, width, height, err := p.image(img)
+	if err != nil {
+		return 0, err
+	}
+
+	content := p.alloc()
+	draw := fmt.Sprintf("q %d 0 0 %d 0 0 cm /Im0 Do Q", width, height)
+	p.dict(content, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(draw), draw))
+
+	page := p.alloc()
+	p.dict(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPages, width, height, xobject, content))
+	return page, p.err
+}
+
+// image writes an image XObject for img and returns its object number
+// and size. JPEG data is copied as is; other formats are decoded.
+func (p *pdfWriter) image(img chapter.ImageFile) (int, int, int, error) {
+	open := img.Open
+	if img.Source != nil {
+		// Converted pages are encoded once and read back from memory
+		var buf bytes.Buffer
+		if err := img.Source.Encode(&buf); err != nil {
+			return 0, 0, 0, err
+		}
+		open = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+		}
+	}
+
+	cfg, format, err := openConfig(open)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	num := p.alloc()
+	if format == "jpeg" {
+		err = p.jpeg(num, cfg, open)
+	} else {
+		err = p.raster(num, open)
+	}
+	return num, cfg.Width, cfg.Height, err
+}
+
+// openConfig reads the header of the image opened by open.
+func openConfig(open func() (io.ReadCloser, error)) (image.Config, string, error) {
+	r, err := open()
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer r.Close()
+	return image.DecodeConfig(r)
+}
+
+// jpeg writes JPEG data unchanged as a DCTDecode image.
+func (p *pdfWriter) jpeg(num int, cfg image.Config, open func() (io.ReadCloser, error)) error {
+	colorSpace := "/DeviceRGB"
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		// Adobe CMYK JPEGs store inverted values
+		colorSpace = "/DeviceCMYK /Decode [1 0 1 0 1 0 1 0]"
+	}
+
+	entries := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+		cfg.Width, cfg.Height, colorSpace)
+	return p.stream(num, entries, func(w io.Writer) error {
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(w, r)
+		return err
+	})
+}
+
+// raster decodes an image and writes its pixels as a Flate-compressed
+// image, gray or RGB, with a soft mask if it has transparency.
+func (p *pdfWriter) raster(num int, open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	bounds := src.Bounds()
+	gray := false
+	switch src.(type) {
+	case *image.Gray, *image.Gray16:
+		gray = true
+	}
+	opaque := true
+	if o, ok := src.(interface{ Opaque() bool }); ok {
+		opaque = o.Opaque()
+	}
+
+	entries := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8 /Filter /FlateDecode",
+		bounds.Dx(), bounds.Dy())
+	if !opaque {
+		mask := p.alloc()
+		err := p.stream(mask, entries+" /ColorSpace /DeviceGray", func(w io.Writer) error {
+			return deflatePixels(w, src, func(c color.NRGBA, row []byte) []byte {
+				return append(row, c.A)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		entries += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+
+	if gray {
+		return p.stream(num, entries+" /ColorSpace /DeviceGray", func(w io.Writer) error {
+			return deflatePixels(w, src, func(c color.NRGBA, row []byte) []byte {
+				return append(row, c.R)
+			})
+		})
+	}
+	return p.stream(num, entries+" /ColorSpace /DeviceRGB", func(w io.Writer) error {
+		return deflatePixels(w, src, func(c color.NRGBA, row []byte) []byte {
+			return append(row, c.R, c.G, c.B)
+		})
+	})
+}
+
+// deflatePixels compresses the samples of src, row by row, into w.
+// sample appends the samples of one pixel to a row.
+func deflatePixels(w io.Writer, src image.Image, sample func(color.NRGBA, []byte) []byte) error {
+	zw := zlib.NewWriter(w)
+	bounds := src.Bounds()
+	var row []byte
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row = sample(color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA), row)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// outline writes the document outline for the bookmarked pages in info
+// and returns its object number, or 0 if there are no bookmarks.
+func (p *pdfWriter) outline(info *ComicInfo, pages []int) int {
+	if info == nil {
+		return 0
+	}
+	var marks []PageInfo
+	for _, page := range info.Pages {
+		if page.Bookmark != "" && page.Image >= 0 && page.Image < len(pages) {
+			marks = append(marks, page)
+		}
+	}
+	if len(marks) == 0 {
+		return 0
+	}
+
+	root := p.alloc()
+	items := make([]int, len(marks))
+	for i := range marks {
+		items[i] = p.alloc()
+	}
+	for i, mark := range marks {
+		item := fmt.Sprintf("/Title %s /Parent %d 0 R /Dest [%d 0 R /Fit]", pdfString(mark.Bookmark), root, pages[mark.Image])
+		if i > 0 {
+			item += fmt.Sprintf(" /Prev %d 0 R", items[i-1])
+		}
+		if i < len(items)-1 {
+			item += fmt.Sprintf(" /Next %d 0 R", items[i+1])
+		}
+		p.dict(items[i], "<< "+item+" >>")
+	}
+	p.dict(root, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", items[0], items[len(items)-1], len(items)))
+	return root
+}
+
+// pdfString encodes s as a PDF text string: a literal string for
+// printable ASCII, otherwise UTF-16BE with a byte order mark, in hex.
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
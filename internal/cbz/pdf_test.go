@@ -0,0 +1,247 @@
+package cbz
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// pdfObjects parses the cross-reference table of a PDF written by
+// CreatePDF and returns each object's text by number, checking that
+// every offset points at its object.
+func pdfObjects(t *testing.T, data []byte) map[int]string {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(data[xref:]), "\n")
+	if lines[0] != "xref" {
+		t.Fatalf("startxref points at %q", lines[0])
+	}
+	var size int
+	fmt.Sscanf(lines[1], "0 %d", &size)
+
+	objects := make(map[int]string)
+	for num := 1; num < size; num++ {
+		offset, _ := strconv.Atoi(lines[2+num][:10])
+		rest := string(data[offset:])
+		header := fmt.Sprintf("%d 0 obj\n", num)
+		if !strings.HasPrefix(rest, header) {
+			t.Fatalf("object %d offset %d points at %q", num, offset, rest[:min(20, len(rest))])
+		}
+		objects[num] = rest[len(header):strings.Index(rest, "endobj")]
+	}
+	return objects
+}
+
+// streamData returns the raw content of a stream object.
+func streamData(object string) []byte {
+	start := strings.Index(object, "stream\n") + len("stream\n")
+	end := strings.LastIndex(object, "\nendstream")
+	return []byte(object[start:end])
+}
+
+func TestCreatePDF(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A transparent RGB page
+	rgba := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	rgba.Set(1, 1, color.NRGBA{R: 200, G: 100, B: 50, A: 128})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		t.Fatal(err)
+	}
+	rgbPath := filepath.Join(tmpDir, "c.png")
+	if err := os.WriteFile(rgbPath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	images := []chapter.ImageFile{
+		createJPEG(t, tmpDir, "a.jpg", 30, 40),
+		createPNG(t, tmpDir, "b.png", 20, 10),
+		{Path: rgbPath, Name: "c.png"},
+	}
+	meta := &ComicInfo{
+		Title: "Vol 1",
+		Pages: PageList{
+			{Image: 0, Bookmark: "Chapter 1"},
+			{Image: 2, Bookmark: "Chapter 2: Café"},
+		},
+	}
+	outputPath := filepath.Join(tmpDir, "Vol 1.pdf")
+	if err := CreatePDF(outputPath, images, PDFOptions{Metadata: meta}); err != nil {
+		t.Fatalf("CreatePDF() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Errorf("missing PDF header: %q", data[:10])
+	}
+	objects := pdfObjects(t, data)
+
+	if pages := objects[pdfPages]; !strings.Contains(pages, "/Count 3") {
+		t.Errorf("page tree = %s, want 3 pages", pages)
+	}
+	if catalog := objects[pdfCatalog]; !strings.Contains(catalog, "/Outlines") {
+		t.Errorf("catalog = %s, want an outline", catalog)
+	}
+
+	var mediaBoxes, titles []string
+	var dct, flate, masks int
+	jpegData, _ := os.ReadFile(images[0].Path)
+	for _, object := range objects {
+		if m := regexp.MustCompile(`/MediaBox \[(.*?)\]`).FindStringSubmatch(object); m != nil {
+			mediaBoxes = append(mediaBoxes, m[1])
+		}
+		if m := regexp.MustCompile(`/Title (\(.*?\)|<.*?>) /Parent`).FindStringSubmatch(object); m != nil {
+			titles = append(titles, m[1])
+		}
+		if !strings.Contains(object, "/Subtype /Image") {
+			continue
+		}
+		switch {
+		case strings.Contains(object, "/DCTDecode"):
+			dct++
+			if !bytes.Equal(streamData(object), jpegData) {
+				t.Error("JPEG page was not embedded unchanged")
+			}
+			if !strings.Contains(object, "/DeviceGray") {
+				t.Errorf("gray JPEG color space: %s", object[:120])
+			}
+		case strings.Contains(object, "/FlateDecode"):
+			flate++
+			zr, err := zlib.NewReader(bytes.NewReader(streamData(object)))
+			if err != nil {
+				t.Fatalf("bad Flate stream: %v", err)
+			}
+			pixels, _ := io.ReadAll(zr)
+			var w, h int
+			fmt.Sscanf(object[strings.Index(object, "/Width"):], "/Width %d /Height %d", &w, &h)
+			components := 3
+			if strings.Contains(object, "/DeviceGray") {
+				components = 1
+			}
+			if len(pixels) != w*h*components {
+				t.Errorf("%dx%d image has %d bytes, want %d", w, h, len(pixels), w*h*components)
+			}
+			if strings.Contains(object, "/SMask") {
+				masks++
+			}
+		}
+	}
+
+	if dct != 1 || flate != 3 || masks != 1 {
+		t.Errorf("images: %d DCT, %d Flate, %d masked; want 1, 3 (including the mask), 1", dct, flate, masks)
+	}
+	for _, want := range []string{"0 0 30 40", "0 0 20 10", "0 0 4 3"} {
+		found := false
+		for _, box := range mediaBoxes {
+			found = found || box == want
+		}
+		if !found {
+			t.Errorf("no page with MediaBox [%s] in %v", want, mediaBoxes)
+		}
+	}
+	if len(titles) != 2 || !strings.Contains(strings.Join(titles, " "), "(Chapter 1)") {
+		t.Errorf("outline titles = %v", titles)
+	}
+	if !bytes.Contains(data, []byte("/Title (Vol 1)")) {
+		t.Error("document title missing")
+	}
+}
+
+func TestCreatePDF_Deterministic(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{createJPEG(t, tmpDir, "a.jpg", 8, 8), createPNG(t, tmpDir, "b.png", 8, 8)}
+
+	var outputs [][]byte
+	for _, name := range []string{"one.pdf", "two.pdf"} {
+		outputPath := filepath.Join(tmpDir, name)
+		opts := PDFOptions{Metadata: &ComicInfo{Title: "Same"}, Deterministic: true}
+		if err := CreatePDF(outputPath, images, opts); err != nil {
+			t.Fatalf("CreatePDF() error = %v", err)
+		}
+		data, _ := os.ReadFile(outputPath)
+		outputs = append(outputs, data)
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("deterministic documents differ")
+	}
+	if bytes.Contains(outputs[0], []byte("/CreationDate")) {
+		t.Error("deterministic document has a creation date")
+	}
+}
+
+func TestCreatePDF_SourcePages(t *testing.T) {
+	tmpDir := t.TempDir()
+	data := jpegBytes(t, 5, 7)
+	images := []chapter.ImageFile{{Path: filepath.Join(tmpDir, "src.png"), Name: "a.jpg", Source: stringSource{content: string(data)}}}
+
+	outputPath := filepath.Join(tmpDir, "out.pdf")
+	if err := CreatePDF(outputPath, images, PDFOptions{}); err != nil {
+		t.Fatalf("CreatePDF() error = %v", err)
+	}
+	if out, _ := os.ReadFile(outputPath); !bytes.Contains(out, data) || !bytes.Contains(out, []byte("/MediaBox [0 0 5 7]")) {
+		t.Error("converted JPEG page was not embedded")
+	}
+}
+
+func TestCreatePDF_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+	bad := filepath.Join(tmpDir, "bad.png")
+	if err := os.WriteFile(bad, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		images []chapter.ImageFile
+	}{
+		{"no pages", nil},
+		{"undecodable page", []chapter.ImageFile{{Path: bad, Name: "bad.png"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tmpDir, "out.pdf")
+			if err := CreatePDF(outputPath, tt.images, PDFOptions{}); err == nil {
+				t.Error("expected error")
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Error("no document should be written")
+			}
+			assertNoTempFiles(t, tmpDir)
+		})
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Chapter 1", "(Chapter 1)"},
+		{`a (b) \c`, `(a \(b\) \\c)`},
+		{"Café", "<FEFF00430061006600E9>"},
+	}
+	for _, tt := range tests {
+		if got := pdfString(tt.in); got != tt.want {
+			t.Errorf("pdfString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
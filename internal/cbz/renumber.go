@@ -0,0 +1,64 @@
+package cbz
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+)
+
+// Rename records an archive entry name changed by Renumber.
+type Rename struct {
+	From string // Original entry name
+	To   string // Entry name in the archive
+}
+
+// String formats the rename as "from -> to".
+func (r Rename) String() string {
+	return r.From + " -> " + r.To
+}
+
+// Renumber returns a copy of images with each entry named after its
+// position, keeping its extension: "1.jpg", "2.png", ... zero-padded to
+// the width of the page count, so 120 pages give "001.jpg" to "120.jpg".
+// Readers that sort entries lexicographically then show the pages in the
+// order given, which is normally the natural order from
+// chapter.CollectImages. Returns the renames made, in page order.
+func Renumber(images []chapter.ImageFile) ([]chapter.ImageFile, []Rename) {
+	width := len(fmt.Sprint(len(images)))
+
+	renamed := make([]chapter.ImageFile, len(images))
+	renames := make([]Rename, len(images))
+	for i, img := range images {
+		name := fmt.Sprintf("%0*d%s", width, i+1, strings.ToLower(filepath.Ext(img.Name)))
+		renames[i] = Rename{From: img.Name, To: name}
+		img.Name = name
+		renamed[i] = img
+	}
+	return renamed, renames
+}
+
+// RenameNotes formats renames one per line, for recording the original
+// entry names in ComicInfo.Notes.
+func RenameNotes(renames []Rename) string {
+	lines := make([]string, len(renames))
+	for i, r := range renames {
+		lines[i] = r.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseRenameNotes reads renames back from notes written with
+// RenameNotes. Lines in any other form are ignored, so notes may hold
+// other text as well.
+func ParseRenameNotes(notes string) []Rename {
+	var renames []Rename
+	for _, line := range strings.Split(notes, "\n") {
+		from, to, ok := strings.Cut(strings.TrimSpace(line), " -> ")
+		if ok && from != "" && to != "" {
+			renames = append(renames, Rename{From: from, To: to})
+		}
+	}
+	return renames
+}
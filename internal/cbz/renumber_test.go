@@ -0,0 +1,95 @@
+package cbz
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestRenumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+		want  []string
+	}{
+		{
+			name:  "single digit count",
+			pages: []string{"img_1.jpg", "img_2.jpg", "credits.PNG"},
+			want:  []string{"1.jpg", "2.jpg", "3.png"},
+		},
+		{
+			name:  "padded to page count",
+			pages: strings.Split("a.jpg b.jpg c.jpg d.jpg e.jpg f.jpg g.jpg h.jpg i.jpg j.webp", " "),
+			want:  strings.Split("01.jpg 02.jpg 03.jpg 04.jpg 05.jpg 06.jpg 07.jpg 08.jpg 09.jpg 10.webp", " "),
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := make([]chapter.ImageFile, len(tt.pages))
+			for i, name := range tt.pages {
+				images[i] = chapter.ImageFile{Path: filepath.Join("/src", name), Name: name}
+			}
+
+			got, renames := Renumber(images)
+			if len(got) != len(tt.want) || len(renames) != len(tt.want) {
+				t.Fatalf("Renumber() returned %d images, %d renames, want %d", len(got), len(renames), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i] {
+					t.Errorf("images[%d].Name = %q, want %q", i, got[i].Name, tt.want[i])
+				}
+				if got[i].Path != images[i].Path {
+					t.Errorf("images[%d].Path = %q, want %q", i, got[i].Path, images[i].Path)
+				}
+				if renames[i] != (Rename{From: tt.pages[i], To: tt.want[i]}) {
+					t.Errorf("renames[%d] = %v", i, renames[i])
+				}
+				if images[i].Name != tt.pages[i] {
+					t.Errorf("input images[%d] was modified", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRenumber_ArchiveWithNotes(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"img_1.png", "img_2.png", "img_10.png"} {
+		createPNG(t, tmpDir, name, 4, 4)
+	}
+	images, err := chapter.CollectImages(tmpDir, []string{"png"})
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+
+	renamed, renames := Renumber(images)
+	outputPath := filepath.Join(tmpDir, "out.cbz")
+	meta := &ComicInfo{Title: "T", Notes: RenameNotes(renames)}
+	if err := Create(outputPath, renamed, CreateOptions{Metadata: meta}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info, names := readComicInfo(t, outputPath)
+	want := []string{ComicInfoName, "1.png", "2.png", "3.png"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+	wantNotes := "img_1.png -> 1.png\nimg_2.png -> 2.png\nimg_10.png -> 3.png"
+	if info.Notes != wantNotes {
+		t.Errorf("Notes = %q, want %q", info.Notes, wantNotes)
+	}
+
+	// Coverage expects the renumbered names
+	if report := CompareSource(outputPath, images, CoverageOptions{Renumber: true}); !report.OK() {
+		t.Errorf("CompareSource() = %s", report)
+	}
+	if report := CompareSource(outputPath, images, CoverageOptions{}); report.OK() {
+		t.Error("CompareSource() without Renumber should report missing pages")
+	}
+}
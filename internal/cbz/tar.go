@@ -0,0 +1,124 @@
+package cbz
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"manga2cbz/internal/chapter"
+)
+
+// tarArchive writes CBT archives: the same entries as a CBZ archive, in
+// an uncompressed tar file.
+type tarArchive struct {
+	opts CreateOptions
+	path string
+	file *os.File
+	tw   *tar.Writer
+}
+
+// Begin implements ArchiveWriter.
+func (a *tarArchive) Begin(outputPath string) error {
+	if err := checkExisting(outputPath, a.opts.Force); err != nil {
+		return err
+	}
+
+	f, err := createTemp(outputPath)
+	if err != nil {
+		return err
+	}
+	a.path, a.file, a.tw = outputPath, f, tar.NewWriter(f)
+	return nil
+}
+
+// AddMetadata implements ArchiveWriter.
+func (a *tarArchive) AddMetadata(info ComicInfo) error {
+	if a.tw == nil {
+		return errNotBegun
+	}
+	data, err := MarshalComicInfo(info)
+	if err != nil {
+		return err
+	}
+	return a.add(ComicInfoName, int64(len(data)), time.Now(), bytes.NewReader(data))
+}
+
+// AddPage implements ArchiveWriter.
+// Tar headers hold the entry size, so pages produced by a Source are
+// encoded into memory first.
+func (a *tarArchive) AddPage(img chapter.ImageFile) error {
+	if a.tw == nil {
+		return errNotBegun
+	}
+
+	info, err := img.Stat()
+	if err != nil {
+		return err
+	}
+
+	if img.Source != nil {
+		var buf bytes.Buffer
+		if err := img.Source.Encode(&buf); err != nil {
+			return err
+		}
+		return a.add(img.Name, int64(buf.Len()), info.ModTime(), &buf)
+	}
+
+	src, err := img.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return a.add(img.Name, info.Size(), info.ModTime(), src)
+}
+
+// add writes a regular file entry with content from r.
+func (a *tarArchive) add(name string, size int64, modTime time.Time, r io.Reader) error {
+	if a.opts.Deterministic {
+		modTime = DeterministicTime
+	}
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modTime.Truncate(time.Second),
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.tw, r)
+	return err
+}
+
+// Commit implements ArchiveWriter.
+func (a *tarArchive) Commit() error {
+	if a.tw == nil {
+		return errNotBegun
+	}
+	if err := a.tw.Close(); err != nil {
+		a.Abort()
+		return err
+	}
+
+	f, temp := a.file, a.file.Name()
+	a.tw, a.file = nil, nil
+	if err := finishTemp(f); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return commitTemp(temp, a.path)
+}
+
+// Abort implements ArchiveWriter.
+func (a *tarArchive) Abort() error {
+	if a.file == nil {
+		return nil
+	}
+	a.file.Close()
+	err := os.Remove(a.file.Name())
+	a.tw, a.file = nil, nil
+	return err
+}
@@ -0,0 +1,65 @@
+package cbz
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestTarArchive(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+	images = append(images, chapter.ImageFile{Path: images[0].Path, Name: "page3.jpg", Source: stringSource{content: "converted"}})
+	outputPath := filepath.Join(tmpDir, "out.cbt")
+
+	opts := CreateOptions{Metadata: &ComicInfo{Title: "T"}, Deterministic: true}
+	w, _ := NewWriter(FormatCBT, opts)
+	if err := CreateWith(w, outputPath, images, opts); err != nil {
+		t.Fatalf("CreateWith() error = %v", err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	var names []string
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bad tar archive: %v", err)
+		}
+		if !header.ModTime.Equal(DeterministicTime) {
+			t.Errorf("%s ModTime = %v, want %v", header.Name, header.ModTime, DeterministicTime)
+		}
+		data, _ := io.ReadAll(tr)
+		names = append(names, header.Name)
+		contents[header.Name] = string(data)
+	}
+
+	want := []string{ComicInfoName, images[0].Name, images[1].Name, "page3.jpg"}
+	if len(names) != len(want) {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("entry %d = %s, want %s", i, names[i], want[i])
+		}
+	}
+	original, _ := os.ReadFile(images[0].Path)
+	if contents[images[0].Name] != string(original) {
+		t.Error("copied page differs from its source")
+	}
+	if contents["page3.jpg"] != "converted" {
+		t.Errorf("converted page = %q", contents["page3.jpg"])
+	}
+}
@@ -0,0 +1,228 @@
+package cbz
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/sort"
+)
+
+// VerifyOptions configures archive verification.
+type VerifyOptions struct {
+	Extensions []string // Image extensions without dots; empty means chapter.DefaultExtensions
+	FullDecode bool     // Decode every image completely, not just its header
+}
+
+// Problem is one issue found in an archive. Entry is empty for problems
+// with the archive as a whole.
+type Problem struct {
+	Entry   string `json:"entry,omitempty"`
+	Message string `json:"message"`
+}
+
+// VerifyReport is the outcome of verifying one archive.
+type VerifyReport struct {
+	Path     string
+	Pages    int       // Image entries found
+	Problems []Problem // Empty if the archive is sound
+}
+
+// OK reports whether no problems were found.
+func (r VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Err returns nil if the archive is sound, or an error counting the
+// problems, so reports can be fed to batch.Run for its exit codes.
+func (r VerifyReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	if len(r.Problems) == 1 {
+		return errors.New("1 problem found")
+	}
+	return fmt.Errorf("%d problems found", len(r.Problems))
+}
+
+// String formats the report for the console: one summary line, then one
+// indented line per problem.
+func (r VerifyReport) String() string {
+	var b strings.Builder
+	if r.OK() {
+		fmt.Fprintf(&b, "%s: OK (%d pages)\n", r.Path, r.Pages)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%s: %v\n", r.Path, r.Err())
+	for _, p := range r.Problems {
+		if p.Entry == "" {
+			fmt.Fprintf(&b, "  %s\n", p.Message)
+		} else {
+			fmt.Fprintf(&b, "  %s: %s\n", p.Entry, p.Message)
+		}
+	}
+	return b.String()
+}
+
+// Verify checks the archive at archivePath more deeply than Validate:
+//   - every entry is read in full, so CRC errors and truncated data show up
+//   - every image header is decoded (the whole image with opts.FullDecode)
+//   - ComicInfo.xml, if present, must parse
+//   - other non-image entries, folders and pages nested in folders are
+//     flagged, since readers may show them as pages or skip them
+//   - pages must be stored in natural sort order
+//
+// Verify never returns an error; an archive that cannot be opened is
+// reported as a problem.
+func Verify(archivePath string, opts VerifyOptions) VerifyReport {
+	report := VerifyReport{Path: archivePath}
+	add := func(entry, format string, args ...interface{}) {
+		report.Problems = append(report.Problems, Problem{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		add("", "cannot open archive: %v", err)
+		return report
+	}
+	defer reader.Close()
+
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = chapter.DefaultExtensions
+	}
+	isImage := extensionSet(extensions)
+
+	var pages []string
+	for _, f := range reader.File {
+		name := f.Name
+
+		if f.FileInfo().IsDir() {
+			add(name, "folder entry")
+			continue
+		}
+		if strings.Contains(name, "/") {
+			add(name, "nested in a folder")
+		}
+
+		switch {
+		case name == ComicInfoName:
+			err = verifyComicInfo(f)
+		case isImage[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]:
+			pages = append(pages, name)
+			err = verifyImage(f, opts.FullDecode)
+		default:
+			add(name, "not an image")
+			err = readEntry(f)
+		}
+		if err != nil {
+			add(name, "%v", err)
+		}
+	}
+	report.Pages = len(pages)
+
+	if problem, ok := orderProblem(pages); ok {
+		report.Problems = append(report.Problems, problem)
+	}
+
+	if report.Pages == 0 {
+		add("", "no pages")
+	}
+	return report
+}
+
+// orderProblem reports the first of the page names, in stored order,
+// that is out of natural sort order. Readers sort pages by name, so the
+// stored order should agree.
+func orderProblem(names []string) (Problem, bool) {
+	sorted := append([]string(nil), names...)
+	sort.Natural(sorted)
+	for i := range names {
+		if names[i] != sorted[i] {
+			return Problem{Entry: names[i], Message: fmt.Sprintf("out of natural sort order, expected %s at position %d", sorted[i], i+1)}, true
+		}
+	}
+	return Problem{}, false
+}
+
+// verifyImage decodes the entry's header (or the whole image if full is
+// set), then reads the rest so the checksum is verified.
+func verifyImage(f *zip.File, full bool) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	r := bufio.NewReader(rc)
+	if full {
+		_, _, err = image.Decode(r)
+	} else {
+		_, _, err = image.DecodeConfig(r)
+	}
+	if err != nil {
+		// Report data errors before the decoder's view of them
+		if _, readErr := io.Copy(io.Discard, r); readErr != nil {
+			return readErr
+		}
+		return fmt.Errorf("corrupt image: %v", err)
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// verifyComicInfo checks that the metadata entry reads and parses.
+func verifyComicInfo(f *zip.File) error {
+	if _, err := readComicInfoEntry(f.Open); err != nil {
+		return fmt.Errorf("invalid metadata: %v", err)
+	}
+	return nil
+}
+
+// readComicInfoEntry reads and parses a ComicInfo.xml entry, opened
+// with open (such as zip.File.Open or chapter.ImageFile.Open).
+func readComicInfoEntry(open func() (io.ReadCloser, error)) (ComicInfo, error) {
+	var info ComicInfo
+	rc, err := open()
+	if err != nil {
+		return info, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return info, err
+	}
+	err = xml.Unmarshal(data, &info)
+	return info, err
+}
+
+// readEntry reads the entry in full so that its checksum is verified.
+func readEntry(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// extensionSet builds a lookup of lowercase extensions without dots.
+func extensionSet(extensions []string) map[string]bool {
+	set := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		set[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return set
+}
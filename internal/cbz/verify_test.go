@@ -0,0 +1,152 @@
+package cbz
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// problemFor returns the message of the first problem for entry, or "".
+func problemFor(r VerifyReport, entry string) string {
+	for _, p := range r.Problems {
+		if p.Entry == entry {
+			return p.Message
+		}
+	}
+	return ""
+}
+
+func TestVerify_SoundArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	images := []chapter.ImageFile{
+		createPNG(t, tmpDir, "2.png", 10, 10),
+		createPNG(t, tmpDir, "10.png", 10, 10),
+	}
+	outputPath := filepath.Join(tmpDir, "sound.cbz")
+	if err := Create(outputPath, images, CreateOptions{Metadata: &ComicInfo{Title: "T"}}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	report := Verify(outputPath, VerifyOptions{FullDecode: true})
+	if !report.OK() || report.Err() != nil {
+		t.Fatalf("Verify() problems = %+v, want none", report.Problems)
+	}
+	if report.Pages != 2 {
+		t.Errorf("Pages = %d, want 2", report.Pages)
+	}
+	if got := report.String(); got != outputPath+": OK (2 pages)\n" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestVerify_ChecksumError(t *testing.T) {
+	tmpDir := t.TempDir()
+	archive := filepath.Join(tmpDir, "corrupt.cbz")
+	page := pngBytes(t, 20, 20)
+	writeZip(t, archive, zipEntry{"01.png", page})
+
+	// Flip a byte near the end of the stored page
+	data, _ := os.ReadFile(archive)
+	at := bytes.Index(data, page) + len(page) - 5
+	data[at] ^= 0xff
+	os.WriteFile(archive, data, 0644)
+
+	// Validate does not read the data, so it misses this
+	if err := Validate(archive); err != nil {
+		t.Fatalf("Validate() error = %v, expected it to pass", err)
+	}
+
+	report := Verify(archive, VerifyOptions{})
+	if msg := problemFor(report, "01.png"); !strings.Contains(msg, "checksum") {
+		t.Errorf("problem for 01.png = %q, want checksum error", msg)
+	}
+}
+
+func TestVerify_CorruptImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	archive := filepath.Join(tmpDir, "pages.cbz")
+	page := pngBytes(t, 64, 64)
+	writeZip(t, archive,
+		zipEntry{"01.png", page},
+		zipEntry{"02.jpg", []byte("not really a jpeg")},
+		zipEntry{"03.png", page[:len(page)/2]},
+	)
+
+	report := Verify(archive, VerifyOptions{})
+	if msg := problemFor(report, "02.jpg"); !strings.Contains(msg, "corrupt image") {
+		t.Errorf("problem for 02.jpg = %q, want corrupt image", msg)
+	}
+	if msg := problemFor(report, "03.png"); msg != "" {
+		t.Errorf("truncated page has a valid header, got problem %q", msg)
+	}
+
+	// Only a full decode catches the truncated image data
+	report = Verify(archive, VerifyOptions{FullDecode: true})
+	if msg := problemFor(report, "03.png"); !strings.Contains(msg, "corrupt image") {
+		t.Errorf("problem for 03.png = %q, want corrupt image", msg)
+	}
+	if msg := problemFor(report, "01.png"); msg != "" {
+		t.Errorf("sound page has problem %q", msg)
+	}
+}
+
+func TestVerify_LayoutProblems(t *testing.T) {
+	tmpDir := t.TempDir()
+	archive := filepath.Join(tmpDir, "messy.cbz")
+	page := pngBytes(t, 4, 4)
+	writeZip(t, archive,
+		zipEntry{"10.png", page},
+		zipEntry{"9.png", page},
+		zipEntry{"notes.txt", []byte("scanned by")},
+		zipEntry{"extras/", nil},
+		zipEntry{"extras/11.png", page},
+		zipEntry{ComicInfoName, []byte("<ComicInfo><Title>")},
+	)
+
+	report := Verify(archive, VerifyOptions{})
+	want := map[string]string{
+		"10.png":        "out of natural sort order",
+		"notes.txt":     "not an image",
+		"extras/":       "folder entry",
+		"extras/11.png": "nested in a folder",
+		ComicInfoName:   "invalid metadata",
+	}
+	for entry, substr := range want {
+		if msg := problemFor(report, entry); !strings.Contains(msg, substr) {
+			t.Errorf("problem for %s = %q, want %q", entry, msg, substr)
+		}
+	}
+	if report.Pages != 3 {
+		t.Errorf("Pages = %d, want 3", report.Pages)
+	}
+	if report.Err() == nil || !strings.Contains(report.String(), "  notes.txt: not an image\n") {
+		t.Errorf("String() = %q", report.String())
+	}
+}
+
+func TestVerify_Unreadable(t *testing.T) {
+	tmpDir := t.TempDir()
+	empty := filepath.Join(tmpDir, "empty.cbz")
+	writeZip(t, empty)
+	notZip := filepath.Join(tmpDir, "bad.cbz")
+	os.WriteFile(notZip, []byte("not a zip"), 0644)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(tmpDir, "missing.cbz"), "cannot open archive"},
+		{notZip, "cannot open archive"},
+		{empty, "no pages"},
+	}
+	for _, tt := range tests {
+		report := Verify(tt.path, VerifyOptions{})
+		if msg := problemFor(report, ""); !strings.Contains(msg, tt.want) {
+			t.Errorf("Verify(%s) problem = %q, want %q", filepath.Base(tt.path), msg, tt.want)
+		}
+	}
+}
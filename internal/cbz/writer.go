@@ -1,90 +1,262 @@
-// Package cbz provides functionality for creating CBZ (Comic Book ZIP) archives.
+// Package cbz provides functionality for creating CBZ (Comic Book ZIP) archives,
+// and EPUB and PDF books from the same pages.
 package cbz
 
 import (
 	"archive/zip"
-	"errors"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"manga2cbz/internal/chapter"
 )
 
 // CreateOptions configures CBZ archive creation behavior.
 type CreateOptions struct {
-	Force bool // Overwrite existing files if true
+	Force    bool       // Overwrite existing files if true
+	Metadata *ComicInfo // Written as ComicInfo.xml when non-nil
+	Comment  string     // ZIP archive comment, e.g. an input fingerprint
+	MaxSize  int64      // Roll over to a new part before exceeding this many bytes; 0 means no limit
+	MaxPages int        // Roll over to a new part after this many pages; 0 means no limit
+
+	// Deterministic makes the archive a function of its inputs alone:
+	// every entry gets the fixed time DeterministicTime instead of a file
+	// or wall clock time, and no extended timestamp extra fields are
+	// written. Entries are still written in the order given.
+	Deterministic bool
 }
 
+// DeterministicTime is the modification time of every entry in archives
+// created with CreateOptions.Deterministic: the earliest time ZIP's DOS
+// timestamps can represent.
+var DeterministicTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// tempSuffix marks archives that are still being written. Create writes
+// to a hidden sibling "." + name + ".<random>" + tempSuffix and renames it
+// into place once the archive is complete.
+const tempSuffix = ".partial"
+
 // Create creates a CBZ archive at outputPath containing the given images.
 // Images are stored at the archive root level using their Name field.
 // Uses Store method (no compression) since images are already compressed.
 // Streams files via io.Copy to avoid loading entire images into memory.
-// Cleans up partial files on error.
-func Create(outputPath string, images []chapter.ImageFile, opts CreateOptions) (err error) {
-	// Check if file exists when Force is false
-	if !opts.Force {
-		if _, statErr := os.Stat(outputPath); statErr == nil {
-			return errors.New("file already exists: " + outputPath)
-		}
-	}
+// If opts.Metadata is set, ComicInfo.xml is written as the first entry,
+// with page count and page dimensions taken from images.
+//
+// The archive is written to a temporary file next to outputPath, synced
+// to disk and renamed into place only once complete, so an interrupted
+// run never leaves a truncated archive at outputPath. Temporary files
+// left for outputPath by earlier interrupted runs are removed first.
+//
+// With opts.MaxSize or opts.MaxPages set, the images may be split into
+// several archives; see CreateParts, which also reports their paths.
+func Create(outputPath string, images []chapter.ImageFile, opts CreateOptions) error {
+	_, err := CreateParts(outputPath, images, opts)
+	return err
+}
 
-	// Create the output file
-	outFile, err := os.Create(outputPath)
+// writeTemp writes the file for outputPath with write to a synced
+// temporary file in the same directory and returns its path. The caller
+// renames it into place with commitTemp, or removes it on failure.
+func writeTemp(outputPath string, write func(io.Writer) error) (string, error) {
+	outFile, err := createTemp(outputPath)
 	if err != nil {
-		return err
+		return "", err
 	}
+	tempPath := outFile.Name()
 
-	// Track whether we completed successfully for cleanup
-	success := false
-	defer func() {
+	// Write the complete file, e.g. up to the ZIP central directory
+	if err := write(outFile); err != nil {
 		outFile.Close()
-		if !success {
-			os.Remove(outputPath)
+		os.Remove(tempPath)
+		return "", err
+	}
+
+	if err := finishTemp(outFile); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
+// createTemp creates the temporary file for outputPath next to it,
+// removing any left by earlier runs.
+func createTemp(outputPath string) (*os.File, error) {
+	dir, name := splitPath(outputPath)
+	removeTemp(dir, name)
+	return os.CreateTemp(dir, "."+name+".*"+tempSuffix)
+}
+
+// finishTemp gives a completely written temporary file the usual
+// permissions, flushes it to disk and closes it.
+func finishTemp(f *os.File) error {
+	// Temp files are private; give the archive the usual permissions
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+
+	// Flush to disk before the rename makes the archive visible
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// commitTemp renames a finished temporary file to outputPath, or removes
+// it if that fails.
+func commitTemp(tempPath, outputPath string) error {
+	if err := os.Rename(tempPath, outputPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// Persist the rename; not supported on every platform
+	dir, _ := splitPath(outputPath)
+	syncDir(dir)
+	return nil
+}
+
+// splitPath splits path into its directory, "." if empty, and file name.
+func splitPath(path string) (string, string) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	return dir, name
+}
+
+// RemoveStaleTemp removes the temporary archives and folders that runs
+// interrupted while writing left next to the planned outputs, including
+// those of split parts. Only names of the exact form createTemp uses,
+// "." + name + ".<digits>" + tempSuffix, are matched. Returns the paths
+// removed.
+func RemoveStaleTemp(outputs []string) ([]string, error) {
+	var removed []string
+	for _, outputPath := range outputs {
+		dir, name := splitPath(outputPath)
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
 		}
-	}()
-
-	// Create ZIP writer
-	zipWriter := zip.NewWriter(outFile)
-	defer func() {
-		if closeErr := zipWriter.Close(); closeErr != nil && err == nil {
-			err = closeErr
-			success = false
+		if err != nil {
+			return removed, err
 		}
-	}()
 
-	// Add each image to the archive
-	for _, img := range images {
-		if addErr := addImageToArchive(zipWriter, img); addErr != nil {
-			return addErr
+		for _, e := range entries {
+			target, ok := tempTarget(e.Name())
+			if !ok {
+				continue
+			}
+			if _, part := partNumber(target, name); target != name && !part {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			switch {
+			case e.Type().IsRegular():
+				err = os.Remove(path)
+			case e.IsDir():
+				err = os.RemoveAll(path)
+			default:
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
 		}
 	}
+	return removed, nil
+}
 
-	success = true
-	return nil
+// removeTemp removes temporary archives or folders for name left in dir
+// by earlier runs. Errors are ignored; a leftover file only wastes space.
+func removeTemp(dir, name string) {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if target, ok := tempTarget(e.Name()); ok && target == name && (e.Type().IsRegular() || e.IsDir()) {
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// tempTarget returns the output name a temporary file was created for,
+// if name is "." + target + ".<digits>" + tempSuffix.
+func tempTarget(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, ".")
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutSuffix(rest, tempSuffix)
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndexByte(rest, '.')
+	if i < 1 || !isDigits(rest[i+1:]) {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isTemp reports whether a file name is one of Create's temporary files.
+func isTemp(name string) bool {
+	_, ok := tempTarget(name)
+	return ok
+}
+
+// syncDir flushes the directory entry changes in dir to disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
 }
 
 // addImageToArchive adds a single image file to the ZIP archive.
 // Uses Store method (no compression) and streams the file content.
-func addImageToArchive(zw *zip.Writer, img chapter.ImageFile) error {
-	// Open source file
-	srcFile, err := os.Open(img.Path)
+// Images with a Source are encoded directly into the entry instead.
+func addImageToArchive(zw *zip.Writer, img chapter.ImageFile, deterministic bool) error {
+	if img.Source != nil {
+		return addSourceToArchive(zw, img, deterministic)
+	}
+
+	// Get file info for modification time
+	info, err := img.Stat()
 	if err != nil {
 		return err
 	}
-	defer srcFile.Close()
 
-	// Get file info for size
-	info, err := srcFile.Stat()
+	// Open source file (or entry of a source archive)
+	srcFile, err := img.Open()
 	if err != nil {
 		return err
 	}
+	defer srcFile.Close()
 
 	// Create ZIP entry header with Store method
 	header := &zip.FileHeader{
 		Name:   img.Name, // Store at root level
 		Method: zip.Store,
 	}
-	header.SetModTime(info.ModTime())
+	setModTime(header, info.ModTime(), deterministic)
 
 	// Create the entry in the archive
 	writer, err := zw.CreateHeader(header)
@@ -97,6 +269,45 @@ func addImageToArchive(zw *zip.Writer, img chapter.ImageFile) error {
 	return err
 }
 
+// addSourceToArchive adds an image whose content is produced by its Source.
+// The entry takes the modification time of the original file at Path.
+func addSourceToArchive(zw *zip.Writer, img chapter.ImageFile, deterministic bool) error {
+	info, err := img.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:   img.Name,
+		Method: zip.Store,
+	}
+	setModTime(header, info.ModTime(), deterministic)
+
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	return img.Source.Encode(writer)
+}
+
+// setModTime records t as the entry's modification time. Deterministic
+// archives record DeterministicTime instead, in the DOS date and time
+// fields only: SetModTime would also add an extended timestamp field.
+func setModTime(header *zip.FileHeader, t time.Time, deterministic bool) {
+	if !deterministic {
+		header.SetModTime(t)
+		return
+	}
+	header.ModifiedDate, header.ModifiedTime = dosTime(DeterministicTime)
+}
+
+// dosTime returns t in ZIP's DOS date and time fields.
+func dosTime(t time.Time) (uint16, uint16) {
+	return uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day()),
+		uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2)
+}
+
 // Validate checks if a CBZ file is a valid ZIP archive.
 // Returns nil if valid, or an error describing the problem.
 func Validate(cbzPath string) error {
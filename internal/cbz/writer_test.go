@@ -2,9 +2,13 @@ package cbz
 
 import (
 	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"manga2cbz/internal/chapter"
 )
@@ -261,3 +265,287 @@ func TestValidate_NonexistentFile(t *testing.T) {
 		t.Error("Validate() should return error for nonexistent file")
 	}
 }
+
+// stringSource is a chapter.Source that writes fixed content.
+type stringSource struct {
+	content string
+	err     error
+}
+
+func (s stringSource) Encode(w io.Writer) error {
+	if s.err != nil {
+		return s.err
+	}
+	_, err := io.WriteString(w, s.content)
+	return err
+}
+
+func TestCreate_SourceImages(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	// Second image is produced on demand from its original file
+	images[1].Name = "image1.png"
+	images[1].Source = stringSource{content: "encoded on demand"}
+
+	if err := Create(outputPath, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) != 2 || reader.File[1].Name != "image1.png" {
+		t.Fatalf("unexpected entries: %v", reader.File)
+	}
+	rc, err := reader.File[1].Open()
+	if err != nil {
+		t.Fatalf("failed to open entry: %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("failed to read entry: %v", err)
+	}
+	if string(data) != "encoded on demand" {
+		t.Errorf("entry content = %q, want %q", data, "encoded on demand")
+	}
+}
+
+func TestCreate_SourceErrorCleansUp(t *testing.T) {
+	tmpDir, images := createTestImages(t, 1)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	images[0].Source = stringSource{err: errors.New("transcode failed")}
+
+	if err := Create(outputPath, images, CreateOptions{}); err == nil {
+		t.Fatal("expected error from failing source")
+	}
+
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("partial output file was not cleaned up")
+	}
+}
+
+func TestCreate_FailureKeepsExistingArchive(t *testing.T) {
+	tmpDir, images := createTestImages(t, 1)
+	outputPath := filepath.Join(tmpDir, "existing.cbz")
+
+	if err := os.WriteFile(outputPath, []byte("original"), 0644); err != nil {
+		t.Fatalf("failed to create existing file: %v", err)
+	}
+
+	images[0].Source = stringSource{err: errors.New("transcode failed")}
+	if err := Create(outputPath, images, CreateOptions{Force: true}); err == nil {
+		t.Fatal("expected error from failing source")
+	}
+
+	// The old archive is only replaced by a complete one
+	content, err := os.ReadFile(outputPath)
+	if err != nil || string(content) != "original" {
+		t.Errorf("existing archive = %q, %v; want it untouched", content, err)
+	}
+	assertNoTempFiles(t, tmpDir)
+}
+
+func TestCreate_NoTempFilesLeft(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	if err := Create(outputPath, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatalf("output not created: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("output permissions = %v, want 0644", info.Mode().Perm())
+	}
+	assertNoTempFiles(t, tmpDir)
+}
+
+func TestCreate_RemovesStaleTempForOutput(t *testing.T) {
+	tmpDir, images := createTestImages(t, 1)
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+
+	stale := filepath.Join(tmpDir, ".output.cbz.12345"+tempSuffix)
+	other := filepath.Join(tmpDir, ".other.cbz.12345"+tempSuffix)
+	for _, path := range []string{stale, other} {
+		if err := os.WriteFile(path, []byte("truncated"), 0644); err != nil {
+			t.Fatalf("failed to create temp file: %v", err)
+		}
+	}
+
+	if err := Create(outputPath, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale temp file for the output was not removed")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("temp file of another archive should be left alone")
+	}
+}
+
+func TestRemoveStaleTemp(t *testing.T) {
+	tmpDir := t.TempDir()
+	nested := filepath.Join(tmpDir, "Series", "Vol 1")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create dirs: %v", err)
+	}
+	outputs := []string{
+		filepath.Join(tmpDir, "ch1.cbz"),
+		filepath.Join(nested, "ch2.cbz"),
+		filepath.Join(tmpDir, "missing", "ch3.cbz"),
+	}
+
+	stale := []string{
+		filepath.Join(tmpDir, ".ch1.cbz.111"+tempSuffix),
+		filepath.Join(nested, ".ch2.cbz.222"+tempSuffix),
+		filepath.Join(nested, ".ch2 (Part 2).cbz.333"+tempSuffix),
+	}
+	kept := []string{
+		filepath.Join(tmpDir, "ch1.cbz"),
+		filepath.Join(nested, "notes"+tempSuffix),
+		filepath.Join(nested, ".notes"+tempSuffix),
+		filepath.Join(nested, ".ch2.cbz.backup"+tempSuffix),
+		filepath.Join(tmpDir, ".other.cbz.444"+tempSuffix),
+		filepath.Join(nested, ".ch1.cbz.555"+tempSuffix),
+	}
+	for _, path := range append(append([]string{}, stale...), kept...) {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	removed, err := RemoveStaleTemp(outputs)
+	if err != nil {
+		t.Fatalf("RemoveStaleTemp() error = %v", err)
+	}
+	if len(removed) != len(stale) {
+		t.Errorf("removed = %v, want %v", removed, stale)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+	for _, path := range kept {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should be kept", path)
+		}
+	}
+}
+
+func TestIsTemp(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{".ch1.cbz.123" + tempSuffix, true},
+		{".Chapter 1.4294967295" + tempSuffix, true},
+		{"ch1.cbz.123" + tempSuffix, false},
+		{".ch1.cbz" + tempSuffix, false},
+		{".ch1.cbz.12a" + tempSuffix, false},
+		{"." + tempSuffix, false},
+		{".ch1.cbz.123", false},
+	}
+	for _, tt := range tests {
+		if got := isTemp(tt.name); got != tt.want {
+			t.Errorf("isTemp(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// assertNoTempFiles fails if dir contains any of Create's temporary files.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	for _, e := range entries {
+		if isTemp(e.Name()) {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestCreate_DeterministicIsReproducible(t *testing.T) {
+	tmpDir, images := createTestImages(t, 2)
+	images = append(images, chapter.ImageFile{
+		Path:   images[0].Path,
+		Name:   "streamed.png",
+		Source: stringSource{content: "transcoded"},
+	})
+	opts := CreateOptions{
+		Metadata:      &ComicInfo{Series: "Series"},
+		Deterministic: true,
+	}
+
+	first := filepath.Join(tmpDir, "first.cbz")
+	if err := Create(first, images, opts); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// Touch the sources; a rebuild must still be identical
+	later := time.Now().Add(time.Hour)
+	for _, img := range images {
+		if err := os.Chtimes(img.Path, later, later); err != nil {
+			t.Fatalf("failed to touch source: %v", err)
+		}
+	}
+
+	second := filepath.Join(tmpDir, "second.cbz")
+	if err := Create(second, images, opts); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	if !bytes.Equal(a, b) {
+		t.Fatal("deterministic archives differ")
+	}
+
+	reader, err := zip.OpenReader(first)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+	for _, f := range reader.File {
+		if !f.Modified.Equal(DeterministicTime) {
+			t.Errorf("%s modified = %v, want %v", f.Name, f.Modified, DeterministicTime)
+		}
+		if len(f.Extra) != 0 {
+			t.Errorf("%s has extra fields %x", f.Name, f.Extra)
+		}
+	}
+}
+
+func TestCreate_DefaultUsesSourceTimes(t *testing.T) {
+	tmpDir, images := createTestImages(t, 1)
+	mtime := time.Date(2020, time.March, 4, 5, 6, 8, 0, time.UTC)
+	if err := os.Chtimes(images[0].Path, mtime, mtime); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+
+	outputPath := filepath.Join(tmpDir, "output.cbz")
+	if err := Create(outputPath, images, CreateOptions{}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+	if got := reader.File[0].Modified; !got.Equal(mtime) {
+		t.Errorf("entry modified = %v, want %v", got, mtime)
+	}
+}
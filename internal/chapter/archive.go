@@ -0,0 +1,276 @@
+package chapter
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"manga2cbz/internal/sort"
+)
+
+// IsArchive reports whether name has the extension of a ZIP archive that
+// is read as a chapter (.zip or .cbz, case-insensitive).
+func IsArchive(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip", ".cbz":
+		return true
+	}
+	return false
+}
+
+// collectArchive lists the images inside the ZIP archive at archivePath
+// whose extensions are in extSet, as CollectImages does for a directory.
+// Pages in folders are flattened to their base names; pages whose base
+// names clash keep their folder path, with "/" replaced by "_". Hidden
+// entries and macOS resource forks are skipped. Pages are sorted
+// naturally by their full path inside the archive. The central directory
+// is read once and shared by the returned images.
+func collectArchive(archivePath string, extSet map[string]bool) ([]ImageFile, error) {
+	absPath, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := openArchive(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.release()
+
+	// Collect matching entry paths
+	var entries []string
+	for _, f := range archive.files {
+		if f.FileInfo().IsDir() || IsJunkEntry(f.Name) {
+			continue
+		}
+		ext := strings.TrimPrefix(path.Ext(f.Name), ".")
+		if extSet[strings.ToLower(ext)] {
+			entries = append(entries, f.Name)
+		}
+	}
+
+	// Natural sort keeps folder order, then page order within folders
+	sort.Natural(entries)
+
+	// Flatten to base names unless that would merge two pages
+	count := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		count[strings.ToLower(path.Base(entry))]++
+	}
+
+	images := make([]ImageFile, len(entries))
+	for i, entry := range entries {
+		name := path.Base(entry)
+		if count[strings.ToLower(name)] > 1 {
+			name = strings.ReplaceAll(entry, "/", "_")
+		}
+		images[i] = ImageFile{
+			Path:    absPath,
+			Name:    name,
+			Entry:   entry,
+			archive: archive,
+		}
+	}
+
+	return images, nil
+}
+
+// ArchiveEntries returns the files in the ZIP archive at archivePath in
+// the order they are stored, skipping folders. Name and Entry are both
+// the entry path. As with the pages CollectImages finds in an archive,
+// the central directory is read once and shared by the returned images.
+func ArchiveEntries(archivePath string) ([]ImageFile, error) {
+	absPath, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := openArchive(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.release()
+
+	var images []ImageFile
+	for _, f := range archive.entries {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		images = append(images, ImageFile{
+			Path:    absPath,
+			Name:    f.Name,
+			Entry:   f.Name,
+			archive: archive,
+		})
+	}
+	return images, nil
+}
+
+// IsJunkEntry reports whether an archive entry is hidden or lies in a
+// hidden folder or a macOS resource fork folder (__MACOSX).
+func IsJunkEntry(name string) bool {
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || component == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveFile is a ZIP archive whose central directory is read once and
+// shared by the pages collected from it. The file itself is only kept
+// open while entries are being read.
+type archiveFile struct {
+	path    string
+	files   map[string]*zip.File
+	entries []*zip.File // In the order they are stored
+
+	mu   sync.Mutex
+	file *os.File
+	refs int
+}
+
+// openArchive opens the ZIP archive at archivePath and reads its central
+// directory. The archive is returned open; call release when done.
+func openArchive(archivePath string) (*archiveFile, error) {
+	a := &archiveFile{path: archivePath}
+	if err := a.acquire(); err != nil {
+		return nil, err
+	}
+
+	info, err := a.file.Stat()
+	if err != nil {
+		a.release()
+		return nil, err
+	}
+	reader, err := zip.NewReader(a, info.Size())
+	if err != nil {
+		a.release()
+		return nil, err
+	}
+
+	a.entries = reader.File
+	a.files = make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		a.files[f.Name] = f
+	}
+	return a, nil
+}
+
+// acquire opens the file if no entry is being read.
+func (a *archiveFile) acquire() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.refs == 0 {
+		f, err := os.Open(a.path)
+		if err != nil {
+			return err
+		}
+		a.file = f
+	}
+	a.refs++
+	return nil
+}
+
+// release closes the file once no entry is being read.
+func (a *archiveFile) release() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refs--
+	if a.refs > 0 {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// ReadAt reads from the open file, for zip.Reader.
+func (a *archiveFile) ReadAt(p []byte, off int64) (int, error) {
+	a.mu.Lock()
+	f := a.file
+	a.mu.Unlock()
+	if f == nil {
+		return 0, os.ErrClosed
+	}
+	return f.ReadAt(p, off)
+}
+
+// open opens the named entry. The file stays open until the entry is
+// closed.
+func (a *archiveFile) open(name string) (io.ReadCloser, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: a.path + ":" + name, Err: fs.ErrNotExist}
+	}
+	if err := a.acquire(); err != nil {
+		return nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		a.release()
+		return nil, err
+	}
+	return &entryReader{ReadCloser: rc, archive: a}, nil
+}
+
+// stat returns file info for the named entry from the central directory.
+func (a *archiveFile) stat(name string) (fs.FileInfo, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: a.path + ":" + name, Err: fs.ErrNotExist}
+	}
+	return f.FileInfo(), nil
+}
+
+// openEntry opens the named entry of the ZIP archive at archivePath, for
+// images not collected by CollectImages. Closing the result also closes
+// the archive.
+func openEntry(archivePath, name string) (io.ReadCloser, error) {
+	a, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+	return a.open(name)
+}
+
+// statEntry returns file info for the named entry of the archive, for
+// images not collected by CollectImages.
+func statEntry(archivePath, name string) (fs.FileInfo, error) {
+	a, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer a.release()
+	return a.stat(name)
+}
+
+// entryReader reads an archive entry and releases its archive when done.
+type entryReader struct {
+	io.ReadCloser
+	archive *archiveFile
+}
+
+// Close closes the entry and releases its archive.
+func (r *entryReader) Close() error {
+	err := r.ReadCloser.Close()
+	if releaseErr := r.archive.release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// isArchiveFile reports whether path is a regular file read as an archive.
+func isArchiveFile(path string) bool {
+	if !IsArchive(path) {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
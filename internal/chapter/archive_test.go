@@ -0,0 +1,313 @@
+package chapter
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createZip writes a ZIP archive at base/path whose entries contain their
+// own names.
+func createZip(t *testing.T, base, path string, entries ...string) string {
+	t.Helper()
+	fullPath := filepath.Join(base, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	f, err := os.Create(fullPath)
+	if err != nil {
+		t.Fatalf("Failed to create archive %s: %v", fullPath, err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create entry %s: %v", name, err)
+		}
+		io.WriteString(w, name)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+	return fullPath
+}
+
+func TestIsArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Chapter 1.zip", true},
+		{"Chapter 1.CBZ", true},
+		{"Chapter 1.rar", false},
+		{"Chapter 1", false},
+		{"page.jpg", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsArchive(tt.name); got != tt.want {
+			t.Errorf("IsArchive(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectImages_Archive(t *testing.T) {
+	root := t.TempDir()
+	archive := createZip(t, root, "ch1.zip",
+		"Chapter 1/10.jpg",
+		"Chapter 1/2.JPG",
+		"Chapter 1/credits.txt",
+		"Chapter 1/.hidden.jpg",
+		"__MACOSX/Chapter 1/._2.JPG",
+		"Chapter 1/",
+		"cover.png",
+	)
+
+	images, err := CollectImages(archive, []string{"jpg", "png"})
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+
+	want := []struct{ name, entry string }{
+		{"2.JPG", "Chapter 1/2.JPG"},
+		{"10.jpg", "Chapter 1/10.jpg"},
+		{"cover.png", "cover.png"},
+	}
+	if len(images) != len(want) {
+		t.Fatalf("CollectImages() = %+v, want %d images", images, len(want))
+	}
+	for i, w := range want {
+		if images[i].Name != w.name || images[i].Entry != w.entry || images[i].Path != archive {
+			t.Errorf("images[%d] = %+v, want name %q entry %q in %s", i, images[i], w.name, w.entry, archive)
+		}
+	}
+}
+
+func TestCollectImages_ArchiveNameClash(t *testing.T) {
+	root := t.TempDir()
+	archive := createZip(t, root, "ch1.cbz", "b/01.jpg", "a/01.jpg", "a/02.jpg")
+
+	images, err := CollectImages(archive, []string{"jpg"})
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+
+	want := []string{"a_01.jpg", "02.jpg", "b_01.jpg"}
+	for i, name := range want {
+		if i >= len(images) || images[i].Name != name {
+			t.Fatalf("CollectImages() = %+v, want names %v", images, want)
+		}
+	}
+}
+
+func TestImageFile_OpenAndStatEntry(t *testing.T) {
+	root := t.TempDir()
+	archive := createZip(t, root, "ch1.zip", "pages/01.jpg")
+	img := ImageFile{Path: archive, Name: "01.jpg", Entry: "pages/01.jpg"}
+
+	rc, err := img.Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(content) != "pages/01.jpg" {
+		t.Errorf("content = %q, %v; want entry content", content, err)
+	}
+
+	info, err := img.Stat()
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size() != int64(len("pages/01.jpg")) {
+		t.Errorf("Stat().Size() = %d, want %d", info.Size(), len("pages/01.jpg"))
+	}
+
+	missing := ImageFile{Path: archive, Name: "02.jpg", Entry: "pages/02.jpg"}
+	if _, err := missing.Open(); !os.IsNotExist(err) {
+		t.Errorf("Open() of missing entry error = %v, want not exist", err)
+	}
+	if _, err := missing.Stat(); !os.IsNotExist(err) {
+		t.Errorf("Stat() of missing entry error = %v, want not exist", err)
+	}
+}
+
+func TestDiscover_FlatArchives(t *testing.T) {
+	root := t.TempDir()
+	createFile(t, root, "Chapter 2/page1.jpg")
+	createZip(t, root, "Chapter 10.zip", "page1.jpg")
+	createZip(t, root, "Chapter 1.cbz", "page1.jpg")
+	createZip(t, root, ".partial.zip", "page1.jpg")
+	createFile(t, root, "notes.txt")
+
+	chapters, err := DiscoverWith(root, DiscoverOptions{IncludeArchives: true})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	want := []struct{ name, path string }{
+		{"Chapter 1", "Chapter 1.cbz"},
+		{"Chapter 2", "Chapter 2"},
+		{"Chapter 10", "Chapter 10.zip"},
+	}
+	if len(chapters) != len(want) {
+		t.Fatalf("Discover() = %+v, want %d chapters", chapters, len(want))
+	}
+	for i, w := range want {
+		if chapters[i].Name != w.name || chapters[i].Path != filepath.Join(root, w.path) {
+			t.Errorf("chapters[%d] = %q at %q, want %q at %q", i, chapters[i].Name, chapters[i].Path, w.name, w.path)
+		}
+	}
+	if chapters[0].Number != "1" {
+		t.Errorf("archive chapter Number = %q, want %q", chapters[0].Number, "1")
+	}
+}
+
+func TestDiscover_RecursiveArchives(t *testing.T) {
+	root := t.TempDir()
+	createZip(t, root, "Series/Vol 1/Ch 1.zip", "01.jpg")
+	createZip(t, root, "Series/Vol 1/Ch 2.zip", "01.jpg")
+	createFile(t, root, "Series/Vol 2/Ch 3/01.jpg")
+
+	chapters, err := DiscoverWith(root, DiscoverOptions{Recursive: true, IncludeArchives: true})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	// A folder of archives is not a chapter itself
+	want := []string{
+		filepath.Join("Series", "Vol 1", "Ch 1"),
+		filepath.Join("Series", "Vol 1", "Ch 2"),
+		filepath.Join("Series", "Vol 2", "Ch 3"),
+	}
+	if len(chapters) != len(want) {
+		t.Fatalf("Discover() = %+v, want %d chapters", chapters, len(want))
+	}
+	for i, name := range want {
+		if chapters[i].Name != name {
+			t.Errorf("chapters[%d].Name = %q, want %q", i, chapters[i].Name, name)
+		}
+	}
+	if chapters[0].Volume != "1" || chapters[0].Series != "Series" {
+		t.Errorf("chapters[0] = %+v, want volume and series from parents", chapters[0])
+	}
+}
+
+func TestDiscover_IgnoresArchivesByDefault(t *testing.T) {
+	tests := []struct {
+		name      string
+		recursive bool
+		files     []string
+		want      []string
+	}{
+		{
+			// A rerun after writing archives into the input directory
+			name:  "flat rerun",
+			files: []string{"Chapter 1/01.jpg", "Chapter 2/01.jpg", "Chapter 1.cbz", "Chapter 2.cbz"},
+			want:  []string{"Chapter 1", "Chapter 2"},
+		},
+		{
+			// Archives next to chapter folders keep them leaves
+			name:      "recursive rerun",
+			recursive: true,
+			files:     []string{"Series/Ch 1/01.jpg", "Series/Ch 1.cbz", "Series/Ch 2/01.jpg", "Series - Ch 2.cbz"},
+			want:      []string{filepath.Join("Series", "Ch 1"), filepath.Join("Series", "Ch 2")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, file := range tt.files {
+				if IsArchive(file) {
+					createZip(t, root, file, "01.jpg")
+				} else {
+					createFile(t, root, file)
+				}
+			}
+
+			chapters, err := Discover(root, tt.recursive)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if len(chapters) != len(tt.want) {
+				t.Fatalf("Discover() = %+v, want %v", chapters, tt.want)
+			}
+			for i, name := range tt.want {
+				if chapters[i].Name != name {
+					t.Errorf("chapters[%d].Name = %q, want %q", i, chapters[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectImages_ArchiveOpenedOnce(t *testing.T) {
+	root := t.TempDir()
+	createZip(t, root, "Chapter 1.cbz", "01.jpg", "02.jpg")
+
+	images, err := CollectImages(filepath.Join(root, "Chapter 1.cbz"), DefaultExtensions)
+	if err != nil {
+		t.Fatalf("CollectImages() error = %v", err)
+	}
+	if len(images) != 2 || images[0].archive == nil || images[0].archive != images[1].archive {
+		t.Fatalf("images = %+v, want two pages sharing one archive", images)
+	}
+
+	// Readers can overlap; the file is closed after the last one
+	first, err := images[0].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	second, err := images[1].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	first.Close()
+	if _, err := io.ReadAll(second); err != nil {
+		t.Errorf("reading second page after closing the first: %v", err)
+	}
+	second.Close()
+	if images[0].archive.file != nil {
+		t.Error("archive left open after every page was closed")
+	}
+	if _, err := images[1].Stat(); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+}
+
+func TestArchiveEntries(t *testing.T) {
+	root := t.TempDir()
+	archive := createZip(t, root, "ch1.cbz", "02.jpg", "b/", "b/01.jpg", "notes.txt")
+
+	images, err := ArchiveEntries(archive)
+	if err != nil {
+		t.Fatalf("ArchiveEntries() error = %v", err)
+	}
+
+	// Stored order, without folders
+	want := []string{"02.jpg", "b/01.jpg", "notes.txt"}
+	if len(images) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(images), len(want))
+	}
+	for i, img := range images {
+		if img.Name != want[i] || img.Entry != want[i] {
+			t.Errorf("images[%d] = %q (entry %q), want %q", i, img.Name, img.Entry, want[i])
+		}
+		if img.archive == nil || img.archive != images[0].archive {
+			t.Errorf("images[%d] does not share the archive", i)
+		}
+	}
+
+	rc, err := images[1].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "b/01.jpg" {
+		t.Errorf("content = %q, want %q", data, "b/01.jpg")
+	}
+}
@@ -4,23 +4,52 @@ package chapter
 import (
 	"os"
 	"path/filepath"
+	stdsort "sort"
 	"strings"
 
 	"manga2cbz/internal/sort"
 )
 
-// Chapter represents a manga chapter directory.
+// Chapter represents a manga chapter directory, or a .zip or .cbz archive
+// read as one.
+// Series, Volume, Number, Title and Group are parsed from the directory
+// name (see ParseName) and are empty when not recognized.
 type Chapter struct {
-	Name string // Directory name (becomes CBZ filename)
-	Path string // Full absolute path to directory
+	Name   string // Directory or archive name without extension (becomes CBZ filename)
+	Path   string // Full absolute path to directory or archive
+	Series string // Series name, falling back to parent or input directory name
+	Volume string // Volume number, e.g. "3"
+	Number string // Chapter number, e.g. "21.5"
+	Title  string // Chapter title
+	Group  string // Scanlation group tag
 }
 
 // Discover finds chapter directories in the input directory.
 // If recursive is false, only immediate subdirectories are considered.
 // If recursive is true, all nested directories containing images are found.
-// Results are sorted in natural order (Chapter 2 before Chapter 10).
+// Results are sorted in natural order (Chapter 2 before Chapter 10),
+// or by volume and chapter number when every chapter has a parsed number.
 // Hidden directories (starting with .) are skipped.
+// Archives are not chapters; see DiscoverWith.
 func Discover(inputDir string, recursive bool) ([]Chapter, error) {
+	return DiscoverWith(inputDir, DiscoverOptions{Recursive: recursive})
+}
+
+// DiscoverOptions configures chapter discovery.
+type DiscoverOptions struct {
+	Recursive bool // Find nested chapter directories, not only immediate ones
+
+	// IncludeArchives makes ZIP archives (.zip and .cbz) chapters too,
+	// wherever a chapter directory would be; see CollectImages for
+	// reading them. It is off by default since the archives written by
+	// an earlier run into the input directory would be found again.
+	IncludeArchives bool
+}
+
+// DiscoverWith finds chapters in the input directory as Discover does,
+// with the given options. Hidden archives are skipped like hidden
+// directories.
+func DiscoverWith(inputDir string, opts DiscoverOptions) ([]Chapter, error) {
 	// Convert to absolute path
 	absPath, err := filepath.Abs(inputDir)
 	if err != nil {
@@ -36,14 +65,14 @@ func Discover(inputDir string, recursive bool) ([]Chapter, error) {
 		return nil, &os.PathError{Op: "discover", Path: absPath, Err: os.ErrInvalid}
 	}
 
-	if recursive {
-		return discoverRecursive(absPath)
+	if opts.Recursive {
+		return discoverRecursive(absPath, opts.IncludeArchives)
 	}
-	return discoverFlat(absPath)
+	return discoverFlat(absPath, opts.IncludeArchives)
 }
 
 // discoverFlat finds chapter directories one level deep.
-func discoverFlat(inputDir string) ([]Chapter, error) {
+func discoverFlat(inputDir string, archives bool) ([]Chapter, error) {
 	entries, err := os.ReadDir(inputDir)
 	if err != nil {
 		return nil, err
@@ -51,20 +80,17 @@ func discoverFlat(inputDir string) ([]Chapter, error) {
 
 	var chapters []Chapter
 	for _, entry := range entries {
-		// Skip non-directories
-		if !entry.IsDir() {
+		// Skip files other than archives
+		if !entry.IsDir() && !(archives && isArchiveEntry(inputDir, entry)) {
 			continue
 		}
 
-		// Skip hidden directories
+		// Skip hidden directories and archives
 		if strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
-		chapters = append(chapters, Chapter{
-			Name: entry.Name(),
-			Path: filepath.Join(inputDir, entry.Name()),
-		})
+		chapters = append(chapters, newChapter(inputDir, entry.Name()))
 	}
 
 	// Sort chapters naturally
@@ -74,9 +100,9 @@ func discoverFlat(inputDir string) ([]Chapter, error) {
 }
 
 // discoverRecursive finds chapter directories at all depths.
-// A directory is considered a chapter if it contains no subdirectories
-// (leaf node in the directory tree).
-func discoverRecursive(inputDir string) ([]Chapter, error) {
+// A directory is considered a chapter if it contains no subdirectories,
+// nor archives when they are chapters (leaf node in the directory tree).
+func discoverRecursive(inputDir string, archives bool) ([]Chapter, error) {
 	var chapters []Chapter
 
 	err := filepath.WalkDir(inputDir, func(path string, d os.DirEntry, err error) error {
@@ -84,8 +110,16 @@ func discoverRecursive(inputDir string) ([]Chapter, error) {
 			return err
 		}
 
-		// Skip non-directories
+		// Archives are always leaf chapters
 		if !d.IsDir() {
+			if !archives || strings.HasPrefix(d.Name(), ".") || !isArchiveEntry(filepath.Dir(path), d) {
+				return nil
+			}
+			relPath, err := filepath.Rel(inputDir, path)
+			if err != nil {
+				return err
+			}
+			chapters = append(chapters, newChapter(inputDir, relPath))
 			return nil
 		}
 
@@ -100,7 +134,7 @@ func discoverRecursive(inputDir string) ([]Chapter, error) {
 		}
 
 		// Check if this directory is a leaf (no subdirectories)
-		isLeaf, err := isLeafDirectory(path)
+		isLeaf, err := isLeafDirectory(path, archives)
 		if err != nil {
 			return err
 		}
@@ -112,10 +146,7 @@ func discoverRecursive(inputDir string) ([]Chapter, error) {
 				return err
 			}
 
-			chapters = append(chapters, Chapter{
-				Name: relPath,
-				Path: path,
-			})
+			chapters = append(chapters, newChapter(inputDir, relPath))
 		}
 
 		return nil
@@ -131,15 +162,64 @@ func discoverRecursive(inputDir string) ([]Chapter, error) {
 	return chapters, nil
 }
 
-// isLeafDirectory returns true if the directory contains no subdirectories.
-func isLeafDirectory(dir string) (bool, error) {
+// newChapter builds a Chapter for relPath, populating the parsed fields.
+// A volume or series missing from the chapter's own name is taken from
+// its parent directories, and the series finally falls back to the name
+// of the input directory.
+func newChapter(inputDir, relPath string) Chapter {
+	name := relPath
+	if IsArchive(relPath) {
+		name = strings.TrimSuffix(relPath, filepath.Ext(relPath))
+	}
+	components := strings.Split(filepath.ToSlash(name), "/")
+	info := ParseName(components[len(components)-1])
+
+	ch := Chapter{
+		Name:   name,
+		Path:   filepath.Join(inputDir, relPath),
+		Series: info.Series,
+		Volume: info.Volume,
+		Number: info.Number,
+		Title:  info.Title,
+		Group:  info.Group,
+	}
+
+	// Walk parents from nearest to outermost
+	for i := len(components) - 2; i >= 0; i-- {
+		parent := ParseName(components[i])
+		if ch.Volume == "" {
+			ch.Volume = parent.Volume
+		}
+		if ch.Series == "" {
+			if parent.Series != "" {
+				ch.Series = parent.Series
+			} else if parent.Volume == "" && parent.Number == "" {
+				// Plain folder name such as "One Piece/"
+				ch.Series = parent.Title
+			}
+		}
+	}
+
+	if ch.Series == "" {
+		ch.Series = filepath.Base(inputDir)
+	}
+
+	return ch
+}
+
+// isLeafDirectory returns true if the directory contains no subdirectories,
+// nor archives if those are chapters of their own.
+func isLeafDirectory(dir string, archives bool) (bool, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return false, err
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() || (archives && isArchiveEntry(dir, entry)) {
 			return false, nil
 		}
 	}
@@ -147,21 +227,95 @@ func isLeafDirectory(dir string) (bool, error) {
 	return true, nil
 }
 
-// sortChapters sorts chapters by name in natural order.
+// isArchiveEntry reports whether a directory entry of dir is a ZIP
+// archive read as a chapter. Symlinks are followed.
+func isArchiveEntry(dir string, entry os.DirEntry) bool {
+	if entry.Type().IsRegular() {
+		return IsArchive(entry.Name())
+	}
+	return isArchiveFile(filepath.Join(dir, entry.Name()))
+}
+
+// sortChapters sorts chapters by name in natural order, then by parsed
+// volume and chapter number. Numbers are only compared between chapters
+// of the same parent directory and series, which are kept together, and
+// only when every chapter of that group has a number.
+// The natural name order breaks ties between equal numbers.
 func sortChapters(chapters []Chapter) {
-	// Extract names for sorting
+	// Extract names for sorting; a folder and an archive can share a name
 	names := make([]string, len(chapters))
-	nameToChapter := make(map[string]Chapter)
+	nameToChapters := make(map[string][]Chapter)
 	for i, ch := range chapters {
 		names[i] = ch.Name
-		nameToChapter[ch.Name] = ch
+		nameToChapters[ch.Name] = append(nameToChapters[ch.Name], ch)
 	}
 
 	// Sort names naturally
 	sort.Natural(names)
 
 	// Rebuild chapters slice in sorted order
-	for i, name := range names {
-		chapters[i] = nameToChapter[name]
+	i := 0
+	for _, name := range names {
+		for _, ch := range nameToChapters[name] {
+			chapters[i] = ch
+			i++
+		}
+		delete(nameToChapters, name)
+	}
+
+	// Numbered chapters are reordered by their parsed numbers, within
+	// each folder and series
+	i = 0
+	for _, run := range sameSeriesRuns(chapters) {
+		sortByNumber(run)
+		i += copy(chapters[i:], run)
 	}
 }
+
+// sameSeriesRuns splits chapters into runs sharing a parent directory and
+// series, in order of first appearance. The runs are copies, so the
+// caller can sort them and write them back.
+func sameSeriesRuns(chapters []Chapter) [][]Chapter {
+	type runKey struct{ parent, series string }
+	index := make(map[runKey]int)
+	var runs [][]Chapter
+	for _, ch := range chapters {
+		key := runKey{filepath.Dir(ch.Name), ch.Series}
+		n, ok := index[key]
+		if !ok {
+			n = len(runs)
+			index[key] = n
+			runs = append(runs, nil)
+		}
+		runs[n] = append(runs[n], ch)
+	}
+	return runs
+}
+
+// sortByNumber stably orders chapters by parsed volume and chapter number.
+// Chapters are left untouched unless all of them have a chapter number,
+// and volumes are only compared when all of them have a volume.
+func sortByNumber(chapters []Chapter) {
+	useVolume := true
+	for _, ch := range chapters {
+		if _, ok := numberValue(ch.Number); !ok {
+			return
+		}
+		if _, ok := numberValue(ch.Volume); !ok {
+			useVolume = false
+		}
+	}
+
+	stdsort.SliceStable(chapters, func(i, j int) bool {
+		if useVolume {
+			vi, _ := numberValue(chapters[i].Volume)
+			vj, _ := numberValue(chapters[j].Volume)
+			if vi != vj {
+				return vi < vj
+			}
+		}
+		ni, _ := numberValue(chapters[i].Number)
+		nj, _ := numberValue(chapters[j].Number)
+		return ni < nj
+	})
+}
@@ -302,3 +302,128 @@ func TestDiscover_RecursiveNaturalOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestDiscover_ParsedFields(t *testing.T) {
+	root := t.TempDir()
+	createDir(t, root, "Vol.03 Ch.021.5 - The Storm")
+
+	chapters, err := Discover(root, false)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(chapters) != 1 {
+		t.Fatalf("Expected 1 chapter, got %d", len(chapters))
+	}
+
+	ch := chapters[0]
+	if ch.Volume != "3" || ch.Number != "21.5" || ch.Title != "The Storm" {
+		t.Errorf("Unexpected parsed fields: %+v", ch)
+	}
+	// Series falls back to the input directory name
+	if ch.Series != filepath.Base(root) {
+		t.Errorf("Expected series %q, got %q", filepath.Base(root), ch.Series)
+	}
+}
+
+func TestDiscover_RecursiveInheritsParentFields(t *testing.T) {
+	root := t.TempDir()
+	createDir(t, root, "One Piece/Volume01/Chapter001")
+	createDir(t, root, "One Piece/Volume01/Chapter002")
+
+	chapters, err := Discover(root, true)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(chapters) != 2 {
+		t.Fatalf("Expected 2 chapters, got %d", len(chapters))
+	}
+
+	for i, ch := range chapters {
+		if ch.Series != "One Piece" {
+			t.Errorf("chapters[%d].Series = %q, want %q", i, ch.Series, "One Piece")
+		}
+		if ch.Volume != "1" {
+			t.Errorf("chapters[%d].Volume = %q, want %q", i, ch.Volume, "1")
+		}
+	}
+	if chapters[1].Number != "2" {
+		t.Errorf("chapters[1].Number = %q, want %q", chapters[1].Number, "2")
+	}
+}
+
+func TestDiscover_OrdersByParsedNumber(t *testing.T) {
+	root := t.TempDir()
+
+	// Natural name order would put "[A]" before "[B]"
+	createDir(t, root, "[B] Ch.2")
+	createDir(t, root, "[A] Ch.10")
+	createDir(t, root, "[C] Ch.2.5")
+
+	chapters, err := Discover(root, false)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	expected := []string{"[B] Ch.2", "[C] Ch.2.5", "[A] Ch.10"}
+	if len(chapters) != len(expected) {
+		t.Fatalf("Expected %d chapters, got %d", len(expected), len(chapters))
+	}
+	for i, exp := range expected {
+		if chapters[i].Name != exp {
+			t.Errorf("Position %d: expected %q, got %q", i, exp, chapters[i].Name)
+		}
+	}
+}
+
+func TestDiscover_MixedNumberingKeepsNaturalOrder(t *testing.T) {
+	root := t.TempDir()
+
+	// "Extras" has no number, so the natural order is kept
+	createDir(t, root, "Ch.10")
+	createDir(t, root, "Ch.2")
+	createDir(t, root, "Extras")
+
+	chapters, err := Discover(root, false)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	expected := []string{"Ch.2", "Ch.10", "Extras"}
+	for i, exp := range expected {
+		if chapters[i].Name != exp {
+			t.Errorf("Position %d: expected %q, got %q", i, exp, chapters[i].Name)
+		}
+	}
+}
+
+func TestDiscover_OrdersByNumberWithinSeries(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"Naruto/Ch.2", "Naruto/Ch.1", "Naruto/Ch.10",
+		"Berserk/Ch.3", "Berserk/Ch.1",
+	} {
+		createFile(t, root, dir+"/page1.jpg")
+	}
+
+	chapters, err := Discover(root, true)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	// Each series stays together, ordered by its own chapter numbers
+	expected := []string{
+		filepath.Join("Berserk", "Ch.1"),
+		filepath.Join("Berserk", "Ch.3"),
+		filepath.Join("Naruto", "Ch.1"),
+		filepath.Join("Naruto", "Ch.2"),
+		filepath.Join("Naruto", "Ch.10"),
+	}
+	if len(chapters) != len(expected) {
+		t.Fatalf("Expected %d chapters, got %d", len(expected), len(chapters))
+	}
+	for i, exp := range expected {
+		if chapters[i].Name != exp {
+			t.Errorf("Position %d: expected %q, got %q", i, exp, chapters[i].Name)
+		}
+	}
+}
@@ -2,6 +2,8 @@
 package chapter
 
 import (
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,27 +11,72 @@ import (
 	"manga2cbz/internal/sort"
 )
 
+// DefaultExtensions are the image extensions treated as pages when the
+// caller does not choose any.
+var DefaultExtensions = []string{"jpg", "jpeg", "png", "gif", "bmp", "webp"}
+
 // ImageFile represents an image file to be included in a CBZ archive.
+// Images read from an archive chapter set Entry; use Open and Stat
+// rather than reading Path directly.
 type ImageFile struct {
-	Path string // Full absolute path to file
-	Name string // Base filename (for archive entry)
+	Path   string // Full absolute path to file, or to the archive holding Entry
+	Name   string // Base filename (for archive entry)
+	Entry  string // Path of the image inside the ZIP archive at Path; empty for plain files
+	Source Source // Produces the content on demand; nil means copy the file at Path
+
+	archive *archiveFile // Archive holding Entry, shared by the pages CollectImages found in it
+}
+
+// Open opens the image file, or its entry inside the archive at Path.
+func (img ImageFile) Open() (io.ReadCloser, error) {
+	if img.archive != nil {
+		return img.archive.open(img.Entry)
+	}
+	if img.Entry != "" {
+		return openEntry(img.Path, img.Entry)
+	}
+	return os.Open(img.Path)
+}
+
+// Stat returns file info for the image file, or for its archive entry.
+func (img ImageFile) Stat() (fs.FileInfo, error) {
+	if img.archive != nil {
+		return img.archive.stat(img.Entry)
+	}
+	if img.Entry != "" {
+		return statEntry(img.Path, img.Entry)
+	}
+	return os.Stat(img.Path)
+}
+
+// Source produces the content of an image on demand, such as a transcoder
+// that encodes a converted page straight into the archive entry.
+// Path still names the original file the content is derived from.
+type Source interface {
+	Encode(w io.Writer) error
 }
 
 // CollectImages finds all image files in a directory matching the given extensions.
 // Returns images sorted in natural order (so "10.jpg" comes after "9.jpg").
 // Extensions are matched case-insensitively without leading dots.
+// If dir is a .zip or .cbz file, the images inside it are returned instead
+// (see IsArchive).
 func CollectImages(dir string, extensions []string) ([]ImageFile, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build extension lookup set (lowercase, without dots)
 	extSet := make(map[string]bool, len(extensions))
 	for _, ext := range extensions {
 		extSet[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
 	}
 
+	if isArchiveFile(dir) {
+		return collectArchive(dir, extSet)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	// Collect matching filenames
 	var names []string
 	for _, entry := range entries {
@@ -0,0 +1,135 @@
+package chapter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NameInfo holds the fields recognized in a scanlation-style folder name
+// such as "[Group] Series - Vol.03 Ch.021.5 - The Storm".
+// Numbers are normalized: leading zeros are dropped and "21,5" becomes "21.5".
+// Fields that could not be recognized are left empty.
+type NameInfo struct {
+	Series string // Text before the volume/chapter markers
+	Volume string // Volume number, e.g. "3"
+	Number string // Chapter number, e.g. "21.5"
+	Title  string // Text after the chapter number
+	Group  string // First bracketed tag, e.g. the scanlation group
+}
+
+var (
+	// groupPattern matches bracketed tags: [Group], (Group), {Group}, 【Group】
+	groupPattern = regexp.MustCompile(`[\[({【]([^\])}】]*)[\])}】]`)
+
+	// volumePattern matches "Vol.03", "Volume 3", "v03"
+	volumePattern = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:vol(?:ume)?\.?|v)\s*(\d+(?:[.,]\d+)?)`)
+
+	// chapterPattern matches "Ch.021.5", "Chapter 21", "c021", "Ep 3"
+	chapterPattern = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:ch(?:apter|ap)?\.?|c|ep(?:isode)?\.?)\s*(\d+(?:[.,]\d+)?)`)
+
+	// kanjiChapterPattern matches "第21話", "第21话", "第21章", "第21回"
+	kanjiChapterPattern = regexp.MustCompile(`第\s*(\d+(?:[.,]\d+)?)\s*[話话章回]`)
+
+	// bareNumberPattern matches a name that is just a number plus optional title: "021", "021 - Title"
+	bareNumberPattern = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)(?:\s|$)`)
+
+	// trailingNumberPattern matches a number at the end of a name: "One Piece 1001"
+	trailingNumberPattern = regexp.MustCompile(`\s(\d+(?:[.,]\d+)?)$`)
+)
+
+// separatorCutset is trimmed from the ends of series and title text.
+const separatorCutset = " -_:.~–—|"
+
+// ParseName extracts series, volume, chapter number, title and group
+// from a single folder name (not a path).
+func ParseName(name string) NameInfo {
+	var info NameInfo
+
+	// Pull out bracketed tags first so they don't leak into the title
+	if m := groupPattern.FindStringSubmatch(name); m != nil {
+		info.Group = strings.TrimSpace(m[1])
+	}
+	rest := strings.TrimSpace(groupPattern.ReplaceAllString(name, " "))
+
+	// start/end track the span occupied by volume and chapter markers
+	start, end := len(rest), 0
+	mark := func(loc []int) {
+		if loc[0] < start {
+			start = loc[0]
+		}
+		if loc[1] > end {
+			end = loc[1]
+		}
+	}
+
+	if loc := volumePattern.FindStringSubmatchIndex(rest); loc != nil {
+		info.Volume = normalizeNumber(rest[loc[2]:loc[3]])
+		mark(loc)
+	}
+
+	chapterLoc := kanjiChapterPattern.FindStringSubmatchIndex(rest)
+	if chapterLoc == nil {
+		chapterLoc = findChapter(rest, end)
+	}
+	if chapterLoc == nil && info.Volume == "" {
+		chapterLoc = bareNumberPattern.FindStringSubmatchIndex(rest)
+		if chapterLoc == nil {
+			chapterLoc = trailingNumberPattern.FindStringSubmatchIndex(rest)
+		}
+	}
+	if chapterLoc != nil {
+		info.Number = normalizeNumber(rest[chapterLoc[2]:chapterLoc[3]])
+		mark(chapterLoc)
+	}
+
+	// No markers at all: the whole name is free text
+	if start > end {
+		info.Title = strings.Trim(rest, separatorCutset)
+		return info
+	}
+
+	info.Series = strings.Trim(rest[:start], separatorCutset)
+	info.Title = strings.Trim(rest[end:], separatorCutset)
+	return info
+}
+
+// findChapter returns the submatch indexes of the chapter marker in s.
+// A chapter marker after the volume marker is preferred, so that
+// "Vol.1 Ch.2" does not mistake anything before the volume for a chapter.
+func findChapter(s string, after int) []int {
+	all := chapterPattern.FindAllStringSubmatchIndex(s, -1)
+	for _, loc := range all {
+		if loc[0] >= after {
+			return loc
+		}
+	}
+	if len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+// normalizeNumber drops leading zeros and uses "." as the decimal separator.
+func normalizeNumber(s string) string {
+	s = strings.Replace(s, ",", ".", 1)
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	if !hasFrac {
+		return intPart
+	}
+	return intPart + "." + fracPart
+}
+
+// numberValue converts a normalized number to a float for ordering.
+// Returns false if s is empty or not a number.
+func numberValue(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
@@ -0,0 +1,61 @@
+package chapter
+
+import "testing"
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want NameInfo
+	}{
+		{"Vol.03 Ch.021.5 - The Storm", NameInfo{Volume: "3", Number: "21.5", Title: "The Storm"}},
+		{"Volume 2 Chapter 10", NameInfo{Volume: "2", Number: "10"}},
+		{"v03 c021", NameInfo{Volume: "3", Number: "21"}},
+		{"Chapter001", NameInfo{Number: "1"}},
+		{"Chapter 5", NameInfo{Number: "5"}},
+		{"Ch. 7: Homecoming", NameInfo{Number: "7", Title: "Homecoming"}},
+		{"ch21,5", NameInfo{Number: "21.5"}},
+		{"[Group] One Piece - Vol.01 Ch.001 - Romance Dawn", NameInfo{
+			Series: "One Piece", Volume: "1", Number: "1", Title: "Romance Dawn", Group: "Group",
+		}},
+		{"One Piece - c1001 [Scans]", NameInfo{Series: "One Piece", Number: "1001", Group: "Scans"}},
+		{"第21話 嵐", NameInfo{Number: "21", Title: "嵐"}},
+		{"021 - Title", NameInfo{Number: "21", Title: "Title"}},
+		{"One Piece 1001", NameInfo{Series: "One Piece", Number: "1001"}},
+		{"Volume01", NameInfo{Volume: "1"}},
+		{"Episode 3", NameInfo{Number: "3"}},
+		{"ChapterA", NameInfo{Title: "ChapterA"}},
+		{"Extras", NameInfo{Title: "Extras"}},
+		{"Chainsaw Man", NameInfo{Title: "Chainsaw Man"}},
+		{"Ch.000", NameInfo{Number: "0"}},
+		{"", NameInfo{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseName(tt.name)
+			if got != tt.want {
+				t.Errorf("ParseName(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"021", "21"},
+		{"021.5", "21.5"},
+		{"21,5", "21.5"},
+		{"000", "0"},
+		{"0.5", "0.5"},
+		{"10", "10"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeNumber(tt.in); got != tt.want {
+			t.Errorf("normalizeNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
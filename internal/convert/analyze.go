@@ -0,0 +1,143 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// FormatAuto picks a target per page from its content: grayscale pages
+// become 8-bit gray PNG, pages with few colors become paletted PNG, and
+// continuous-tone color pages become JPEG.
+const FormatAuto Format = "auto"
+
+const (
+	// grayTolerance is the largest channel spread (0-255) of a pixel that
+	// still counts as gray, to absorb chroma noise from lossy sources.
+	grayTolerance = 12
+
+	// grayOutlierRatio is the fraction of colored pixels a page may have
+	// and still be treated as grayscale (stray specks, scan artifacts).
+	grayOutlierRatio = 0.001
+
+	// maxPaletteColors is the largest number of distinct colors stored as
+	// a paletted PNG.
+	maxPaletteColors = 256
+)
+
+// resolveAuto analyzes img and returns the concrete target format, the
+// image to encode (reduced to gray or paletted when that applies), and a
+// short description of the decision for the verbose log.
+func resolveAuto(img image.Image) (Format, image.Image, string) {
+	if isGrayscale(img) {
+		return FormatPNG, toGray(img), "grayscale"
+	}
+	if palette := distinctColors(img, maxPaletteColors); palette != nil {
+		return FormatPNG, toPaletted(img, palette), fmt.Sprintf("%d colors", len(palette))
+	}
+	return FormatJPEG, img, "continuous-tone color"
+}
+
+// isGrayscale reports whether img is opaque and all but a few of its
+// pixels have nearly equal red, green and blue channels.
+func isGrayscale(img image.Image) bool {
+	switch m := img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	case *image.YCbCr:
+		return isGrayYCbCr(m)
+	}
+
+	bounds := img.Bounds()
+	allowed := int(float64(bounds.Dx()*bounds.Dy()) * grayOutlierRatio)
+	outliers := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a != 0xffff {
+				return false
+			}
+			if spread(r>>8, g>>8, b>>8) > grayTolerance {
+				outliers++
+				if outliers > allowed {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// isGrayYCbCr checks chroma planes directly: gray pixels have Cb and Cr
+// close to 128, which avoids converting every pixel to RGB.
+func isGrayYCbCr(m *image.YCbCr) bool {
+	allowed := int(float64(len(m.Cb)) * grayOutlierRatio)
+	outliers := 0
+	for i := range m.Cb {
+		if absDiff(uint32(m.Cb[i]), 128) > grayTolerance/2 || absDiff(uint32(m.Cr[i]), 128) > grayTolerance/2 {
+			outliers++
+			if outliers > allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// distinctColors returns the colors used by img, or nil if there are
+// more than limit of them.
+func distinctColors(img image.Image, limit int) color.Palette {
+	seen := make(map[color.RGBA64]bool)
+	var palette color.Palette
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			c := color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+			if seen[c] {
+				continue
+			}
+			if len(palette) == limit {
+				return nil
+			}
+			seen[c] = true
+			palette = append(palette, c)
+		}
+	}
+	return palette
+}
+
+// toGray converts img to 8-bit grayscale.
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+	return gray
+}
+
+// toPaletted converts img to a paletted image. Every pixel of img must
+// be in palette, so the conversion is exact.
+func toPaletted(img image.Image, palette color.Palette) *image.Paletted {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, palette)
+	draw.Draw(paletted, bounds, img, bounds.Min, draw.Src)
+	return paletted
+}
+
+// spread returns the difference between the largest and smallest channel.
+func spread(r, g, b uint32) uint32 {
+	return max(r, g, b) - min(r, g, b)
+}
+
+// absDiff returns |a - b|.
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,163 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// grayImage returns an RGBA image containing only shades of gray.
+func grayImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*7 + y*13) % 256)
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// photoImage returns an RGBA image with a continuous color gradient.
+func photoImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), uint8((x + y) * 4), 255})
+		}
+	}
+	return img
+}
+
+func TestResolveAuto(t *testing.T) {
+	// Four flat colors, like a simple color insert
+	fewColors := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			fewColors.Set(x, y, []color.Color{
+				color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255},
+				color.RGBA{0, 0, 255, 255}, color.RGBA{255, 255, 255, 255},
+			}[(x+y)%4])
+		}
+	}
+
+	// Grayscale with a single colored speck still counts as gray
+	speck := grayImage(64, 64).(*image.RGBA)
+	speck.Set(3, 3, color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		name       string
+		img        image.Image
+		wantFormat Format
+		wantType   string
+	}{
+		{"grayscale", grayImage(32, 32), FormatPNG, "*image.Gray"},
+		{"gray with speck", speck, FormatPNG, "*image.Gray"},
+		{"few colors", fewColors, FormatPNG, "*image.Paletted"},
+		{"photo", photoImage(32, 32), FormatJPEG, "*image.RGBA"},
+		{"gray YCbCr", image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420), FormatPNG, "*image.Gray"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// YCbCr zero value has Cb=Cr=0, so center the chroma first
+			if ycc, ok := tt.img.(*image.YCbCr); ok {
+				for i := range ycc.Cb {
+					ycc.Cb[i], ycc.Cr[i] = 128, 128
+				}
+			}
+
+			format, out, _ := resolveAuto(tt.img)
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+			if got := fmt.Sprintf("%T", out); got != tt.wantType {
+				t.Errorf("encoded image type = %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestResolveAuto_TransparentIsNotGray(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(0, 0, color.NRGBA{128, 128, 128, 255})
+
+	format, out, _ := resolveAuto(img)
+	if format != FormatPNG {
+		t.Errorf("format = %q, want %q", format, FormatPNG)
+	}
+	if _, ok := out.(*image.Gray); ok {
+		t.Error("transparent image must not be reduced to gray")
+	}
+}
+
+func TestConvertImages_AutoLogsDecisions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	grayPath := filepath.Join(tempDir, "01.png")
+	writePNG(t, grayPath, grayImage(16, 16))
+	photoPath := filepath.Join(tempDir, "02.png")
+	writePNG(t, photoPath, photoImage(32, 32))
+
+	images := []chapter.ImageFile{
+		{Path: grayPath, Name: "01.png"},
+		{Path: photoPath, Name: "02.png"},
+	}
+
+	var log bytes.Buffer
+	opts := Options{
+		Policy:  Policy{Targets: map[string]Format{"png": FormatAuto}},
+		Verbose: &log,
+	}
+
+	result, cleanup, err := ConvertImages(images, opts)
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	if result[0].Name != "01.png" || result[1].Name != "02.jpg" {
+		t.Errorf("names = %q, %q; want 01.png, 02.jpg", result[0].Name, result[1].Name)
+	}
+	verifyJPEG(t, result[1].Path)
+
+	// Gray page is stored as an 8-bit gray PNG
+	f, err := os.Open(result[0].Path)
+	if err != nil {
+		t.Fatalf("failed to open converted page: %v", err)
+	}
+	defer f.Close()
+	decoded, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("converted page is not a valid PNG: %v", err)
+	}
+	if _, ok := decoded.(*image.Gray); !ok {
+		t.Errorf("gray page decoded as %T, want *image.Gray", decoded)
+	}
+
+	for _, want := range []string{"01.png -> 01.png (grayscale)", "02.png -> 02.jpg (continuous-tone color)"} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("verbose log missing %q:\n%s", want, log.String())
+		}
+	}
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("failed to encode %s: %v", path, err)
+	}
+}
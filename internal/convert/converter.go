@@ -2,33 +2,130 @@
 package convert
 
 import (
-	"image/png"
+	"fmt"
+	"image"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
-
-	"golang.org/x/image/webp"
+	"sync"
 
 	"manga2cbz/internal/chapter"
 )
 
-// ConvertWebPImages converts any WebP images in the slice to PNG format.
+// DefaultMemoryBudget is the default limit on the estimated memory used
+// by decoded pages that are being converted at the same time.
+const DefaultMemoryBudget = 512 << 20
+
+// Options configures image conversion.
+type Options struct {
+	Workers      int       // Pages converted concurrently; < 1 means runtime.NumCPU()
+	MemoryBudget int64     // Estimated bytes of decoded pages in flight; < 1 means DefaultMemoryBudget
+	Policy       Policy    // Which formats to convert; the zero value converts WebP to PNG
+	Trim         Trim      // Cropping of uniform page margins; disabled by default
+	Split        Split     // Splitting of double-page spreads; disabled by default
+	Resize       Resize    // Size limits applied to every page; the zero value disables resizing
+	Verbose      io.Writer // Receives per-page decisions (e.g. FormatAuto); nil disables
+}
+
+// Fingerprint returns a canonical description of the options that affect
+// the converted pages, for use as fingerprint.Of's settings. Defaults are
+// filled in, targets normalized and disabled features left out, so
+// equivalent options give the same string. Workers, MemoryBudget and
+// Verbose do not change the pages and are not included.
+func (o Options) Fingerprint() string {
+	p := o.Policy.withDefaults()
+	targets := make([]string, 0, len(p.Targets))
+	for src, f := range p.Targets {
+		targets = append(targets, src+"="+string(f))
+	}
+	sort.Strings(targets)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "targets=%s;jpeg=%d;png=%d", strings.Join(targets, ","), p.JPEGQuality, p.PNGCompression)
+	if o.Trim.Enabled {
+		t := o.Trim.withDefaults()
+		fmt.Fprintf(&b, ";trim=%d,%g,%g", t.Tolerance, t.Noise, t.MaxFraction)
+	}
+	if o.Split.Enabled {
+		ratio := o.Split.MinRatio
+		if ratio <= 0 {
+			ratio = DefaultSpreadRatio
+		}
+		fmt.Fprintf(&b, ";split=%g,%t,%t", ratio, o.Split.RightToLeft, o.Split.KeepSpread)
+	}
+	if o.Resize.enabled() {
+		fmt.Fprintf(&b, ";resize=%dx%d,%d", o.Resize.MaxWidth, o.Resize.MaxHeight, o.Resize.MaxPixels)
+	}
+	return b.String()
+}
+
+// logger serializes verbose output from concurrent workers.
+type logger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// printf writes one line of verbose output if a writer is configured.
+func (l *logger) printf(format string, args ...interface{}) {
+	if l.w == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, format+"\n", args...)
+}
+
+// ConvertWebPImages converts any WebP images in the slice to PNG format
+// using default Options. See ConvertImages.
+func ConvertWebPImages(images []chapter.ImageFile) ([]chapter.ImageFile, func(), error) {
+	return ConvertImages(images, Options{})
+}
+
+// ConvertImages converts images according to opts.Policy (by default,
+// WebP to PNG), trims margins and splits spreads when opts.Trim and
+// opts.Split are enabled, and scales down pages that exceed opts.Resize,
+// whatever their format.
 // Converted files are written to a temporary directory.
 // Returns an updated ImageFile slice with converted paths and a cleanup function.
 // The cleanup function should be called (typically via defer) to remove temp files.
-// Non-WebP files are passed through unchanged.
-func ConvertWebPImages(images []chapter.ImageFile) ([]chapter.ImageFile, func(), error) {
-	// Quick check: any WebP files?
-	hasWebP := false
-	for _, img := range images {
-		if isWebP(img.Name) {
-			hasWebP = true
-			break
+// Pages that need no change are passed through unchanged, and page order is preserved.
+// It is an error for two pages to end up with the same name (see
+// checkNames). Without trimming this is checked before any page is
+// converted; with trimming pages are only planned as they are converted,
+// so the check runs once all of them are done.
+//
+// Pages are converted concurrently, limited by opts.Workers and by
+// opts.MemoryBudget: a page only starts once its estimated decoded size
+// fits in the budget, and a page larger than the whole budget runs alone.
+// The first failure stops pending conversions and removes the temp directory.
+func ConvertImages(images []chapter.ImageFile, opts Options) ([]chapter.ImageFile, func(), error) {
+	pipe := newPipeline(opts)
+
+	// Quick check: anything to convert?
+	var pending []int
+	plans := make([][]page, len(images))
+	for i, img := range images {
+		// Trimming decodes every page to plan it; leave that to the workers
+		if pipe.trim.Enabled {
+			pending = append(pending, i)
+			continue
+		}
+		plans[i], _ = pipe.plan(img)
+		if len(plans[i]) != 1 || !plans[i][0].unchanged() {
+			pending = append(pending, i)
+		}
+	}
+	if !pipe.trim.Enabled {
+		if err := checkNames(images, plans); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	// No WebP files, return as-is with no-op cleanup
-	if !hasWebP {
+	// Nothing to convert, return as-is with no-op cleanup
+	if len(pending) == 0 {
 		return images, func() {}, nil
 	}
 
@@ -42,24 +139,89 @@ func ConvertWebPImages(images []chapter.ImageFile) ([]chapter.ImageFile, func(),
 		os.RemoveAll(tempDir)
 	}
 
-	// Process images, converting WebP to PNG
-	result := make([]chapter.ImageFile, len(images))
+	// Pages produced from each source image; untouched images pass through
+	outputs := make([][]chapter.ImageFile, len(images))
 	for i, img := range images {
-		if !isWebP(img.Name) {
-			// Pass through non-WebP files unchanged
-			result[i] = img
-			continue
-		}
+		outputs[i] = []chapter.ImageFile{img}
+	}
 
-		// Convert WebP to PNG
-		converted, err := convertWebPToPNG(img, tempDir)
-		if err != nil {
-			cleanup()
-			return nil, nil, err
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+	limit := opts.MemoryBudget
+	if limit < 1 {
+		limit = DefaultMemoryBudget
+	}
+	mem := newMemoryBudget(limit)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		stop     = make(chan struct{})
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			close(stop)
+			mem.cancel()
+		})
+	}
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for _, i := range pending {
+			select {
+			case indexes <- i:
+			case <-stop:
+				return
+			}
 		}
-		result[i] = converted
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				reserved, ok := mem.acquire(estimateMemory(images[i]))
+				if !ok {
+					return
+				}
+				pages, src := plans[i], image.Image(nil)
+				if pages == nil {
+					pages, src = pipe.plan(images[i])
+					plans[i] = pages
+				}
+				converted, err := convertImage(images[i], i, pages, src, pipe, tempDir)
+				mem.release(reserved)
+				if err != nil {
+					fail(err)
+					return
+				}
+				outputs[i] = converted
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr == nil && pipe.trim.Enabled {
+		firstErr = checkNames(images, plans)
+	}
+	if firstErr != nil {
+		cleanup()
+		return nil, nil, firstErr
 	}
 
+	var result []chapter.ImageFile
+	for _, out := range outputs {
+		result = append(result, out...)
+	}
 	return result, cleanup, nil
 }
 
@@ -69,27 +231,108 @@ func isWebP(filename string) bool {
 	return ext == ".webp"
 }
 
-// convertWebPToPNG converts a single WebP image to PNG format.
-// The converted file is written to the temp directory.
-// Returns an ImageFile with updated path and name.
-func convertWebPToPNG(img chapter.ImageFile, tempDir string) (chapter.ImageFile, error) {
-	// Open source WebP file
-	srcFile, err := os.Open(img.Path)
+// estimateMemory estimates the bytes needed to decode and re-encode the
+// image, from its header dimensions (4 bytes per pixel).
+// Returns 0 if the header cannot be read; decoding will report the error.
+func estimateMemory(img chapter.ImageFile) int64 {
+	cfg, err := decodeImageConfig(img)
 	if err != nil {
-		return chapter.ImageFile{}, err
+		return 0
 	}
-	defer srcFile.Close()
+	return int64(cfg.Width) * int64(cfg.Height) * 4
+}
 
-	// Decode WebP image
-	decodedImg, err := webp.Decode(srcFile)
-	if err != nil {
-		return chapter.ImageFile{}, err
+// memoryBudget is a counting semaphore over estimated bytes.
+type memoryBudget struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int64
+	used     int64
+	canceled bool
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until n bytes fit in the budget and reserves them.
+// Requests larger than the limit are clamped, so they wait for the budget
+// to drain and then run alone. Returns the reserved amount, which must be
+// passed to release, and false if the budget was canceled while waiting.
+func (b *memoryBudget) acquire(n int64) (int64, bool) {
+	if n > b.limit {
+		n = b.limit
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.canceled && b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	if b.canceled {
+		return 0, false
 	}
+	b.used += n
+	return n, true
+}
+
+// release returns n reserved bytes to the budget.
+func (b *memoryBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// cancel wakes all waiters and makes further acquires fail.
+func (b *memoryBudget) cancel() {
+	b.mu.Lock()
+	b.canceled = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// convertImage produces the planned pages of the source image at index.
+// src is the decoded image if planning already decoded it, or nil. Converted files are written to the temp directory;
+// unchanged pages keep the source file. Returns the pages in reading order.
+func convertImage(img chapter.ImageFile, index int, pages []page, src image.Image, pipe *pipeline, tempDir string) ([]chapter.ImageFile, error) {
+	result := make([]chapter.ImageFile, len(pages))
+	for i, pg := range pages {
+		if pg.unchanged() {
+			result[i] = img
+			continue
+		}
+
+		// Decode once for all pages of the source
+		if src == nil {
+			var err error
+			if src, err = decodeImage(img); err != nil {
+				return nil, err
+			}
+		}
+
+		converted, err := convertPage(src, index, pg, pipe, tempDir)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
+// convertPage renders one output page and writes it to the temp directory.
+// The temp file name is prefixed with the source index, so pages from
+// different sources never share a file even if their names clash.
+// Returns an ImageFile with updated path and name.
+func convertPage(src image.Image, index int, pg page, pipe *pipeline, tempDir string) (chapter.ImageFile, error) {
+	// Apply the enabled stages to the decoded source image
+	decodedImg, format := pipe.render(src, pg)
 
-	// Generate new filename with .png extension
-	baseName := strings.TrimSuffix(img.Name, filepath.Ext(img.Name))
-	newName := baseName + ".png"
-	newPath := filepath.Join(tempDir, newName)
+	// Generate new filename with the target extension
+	newName := renamed(pg.name, format)
+	newPath := filepath.Join(tempDir, fmt.Sprintf("%d_%s", index, newName))
 
 	// Create destination file
 	dstFile, err := os.Create(newPath)
@@ -98,8 +341,8 @@ func convertWebPToPNG(img chapter.ImageFile, tempDir string) (chapter.ImageFile,
 	}
 	defer dstFile.Close()
 
-	// Encode as PNG
-	if err := png.Encode(dstFile, decodedImg); err != nil {
+	// Encode in the target format
+	if err := pipe.policy.encode(dstFile, decodedImg, format); err != nil {
 		return chapter.ImageFile{}, err
 	}
 
@@ -1,11 +1,14 @@
 package convert
 
 import (
+	"archive/zip"
 	"encoding/base64"
+	"fmt"
 	"image"
 	"image/png"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"manga2cbz/internal/chapter"
@@ -288,3 +291,207 @@ func verifyPNG(t *testing.T, path string) {
 		t.Errorf("file %s is not a valid PNG: %v", path, err)
 	}
 }
+
+func TestConvertImages_ParallelPreservesOrder(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var images []chapter.ImageFile
+	var expected []string
+	for i := 1; i <= 24; i++ {
+		name := fmt.Sprintf("%02d", i)
+		if i%3 == 0 {
+			path := filepath.Join(tempDir, name+".png")
+			createTestPNG(t, path)
+			images = append(images, chapter.ImageFile{Path: path, Name: name + ".png"})
+		} else {
+			path := filepath.Join(tempDir, name+".webp")
+			createTestFile(t, path, minimalWebP)
+			images = append(images, chapter.ImageFile{Path: path, Name: name + ".webp"})
+		}
+		expected = append(expected, name+".png")
+	}
+
+	result, cleanup, err := ConvertImages(images, Options{Workers: 4})
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	if len(result) != len(expected) {
+		t.Fatalf("result length = %d, want %d", len(result), len(expected))
+	}
+	for i, img := range result {
+		if img.Name != expected[i] {
+			t.Errorf("result[%d].Name = %q, want %q", i, img.Name, expected[i])
+		}
+	}
+	verifyPNG(t, result[0].Path)
+}
+
+func TestConvertImages_TinyMemoryBudget(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Every page exceeds the budget, so pages run one at a time
+	var images []chapter.ImageFile
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(tempDir, fmt.Sprintf("%d.webp", i))
+		createTestFile(t, path, minimalWebP)
+		images = append(images, chapter.ImageFile{Path: path, Name: filepath.Base(path)})
+	}
+
+	result, cleanup, err := ConvertImages(images, Options{Workers: 4, MemoryBudget: 1})
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	if len(result) != 4 {
+		t.Errorf("result length = %d, want 4", len(result))
+	}
+}
+
+func TestConvertImages_FailureRemovesTempDir(t *testing.T) {
+	// Isolate temp dirs so leftovers can be detected
+	tmpRoot := t.TempDir()
+	t.Setenv("TMPDIR", tmpRoot)
+
+	srcDir := t.TempDir()
+	var images []chapter.ImageFile
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(srcDir, fmt.Sprintf("%d.webp", i))
+		content := minimalWebP
+		if i == 5 {
+			content = []byte("not valid webp data")
+		}
+		createTestFile(t, path, content)
+		images = append(images, chapter.ImageFile{Path: path, Name: filepath.Base(path)})
+	}
+
+	_, _, err := ConvertImages(images, Options{Workers: 3})
+	if err == nil {
+		t.Fatal("ConvertImages should return error for invalid WebP")
+	}
+
+	leftovers, err := filepath.Glob(filepath.Join(tmpRoot, "manga2cbz-convert-*"))
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("temp directories not cleaned up: %v", leftovers)
+	}
+}
+
+func TestMemoryBudget_ClampsAndCancels(t *testing.T) {
+	b := newMemoryBudget(100)
+
+	// Oversized request is clamped to the whole budget
+	reserved, ok := b.acquire(1000)
+	if !ok || reserved != 100 {
+		t.Fatalf("acquire(1000) = %d, %v; want 100, true", reserved, ok)
+	}
+
+	// A waiter blocked on a full budget returns false when canceled
+	done := make(chan bool)
+	go func() {
+		_, ok := b.acquire(1)
+		done <- ok
+	}()
+	b.cancel()
+	if <-done {
+		t.Error("acquire should fail after cancel")
+	}
+
+	b.release(reserved)
+}
+
+func TestConvertImages_ArchiveEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	archive := filepath.Join(tempDir, "chapter.zip")
+
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"pages/01.webp", "pages/02.png"} {
+		w, _ := zw.Create(name)
+		if strings.HasSuffix(name, ".webp") {
+			w.Write(minimalWebP)
+		} else {
+			png.Encode(w, grayImage(4, 4))
+		}
+	}
+	zw.Close()
+	f.Close()
+
+	images := []chapter.ImageFile{
+		{Path: archive, Name: "01.webp", Entry: "pages/01.webp"},
+		{Path: archive, Name: "02.png", Entry: "pages/02.png"},
+	}
+	result, cleanup, err := ConvertImages(images, Options{})
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	// The WebP entry is decoded from the archive into a temp file
+	if result[0].Name != "01.png" || result[0].Entry != "" {
+		t.Errorf("result[0] = %+v, want converted temp file 01.png", result[0])
+	}
+	verifyPNG(t, result[0].Path)
+
+	// Kept entries still point into the archive
+	if result[1] != images[1] {
+		t.Errorf("result[1] = %+v, want %+v", result[1], images[1])
+	}
+}
+
+func TestOptions_Fingerprint(t *testing.T) {
+	base := Options{Resize: Resize{MaxWidth: 1200}}
+
+	// Options that do not change the pages give the same fingerprint
+	same := []Options{
+		{Resize: Resize{MaxWidth: 1200}, Workers: 8, MemoryBudget: 1 << 20, Verbose: &strings.Builder{}},
+		{Resize: Resize{MaxWidth: 1200}, Policy: DefaultPolicy()},
+		{Resize: Resize{MaxWidth: 1200}, Policy: Policy{JPEGQuality: DefaultJPEGQuality}},
+		{Resize: Resize{MaxWidth: 1200}, Policy: Policy{Targets: map[string]Format{"webp": FormatPNG, "png": FormatPNG, "gif": FormatKeep}}},
+		{Resize: Resize{MaxWidth: 1200}, Policy: Policy{Targets: map[string]Format{"WEBP": FormatPNG}}},
+		{Resize: Resize{MaxWidth: 1200}, Split: Split{RightToLeft: true}},
+		{Resize: Resize{MaxWidth: 1200}, Trim: Trim{Tolerance: 40}},
+	}
+	for i, opts := range same {
+		if got, want := opts.Fingerprint(), base.Fingerprint(); got != want {
+			t.Errorf("same[%d].Fingerprint() = %q, want %q", i, got, want)
+		}
+	}
+
+	different := []Options{
+		{},
+		{Resize: Resize{MaxWidth: 1000}},
+		{Resize: Resize{MaxWidth: 1200}, Policy: Policy{Targets: map[string]Format{"webp": FormatJPEG}}},
+		{Resize: Resize{MaxWidth: 1200}, Policy: Policy{JPEGQuality: 75}},
+		{Resize: Resize{MaxWidth: 1200}, Split: Split{Enabled: true}},
+		{Resize: Resize{MaxWidth: 1200}, Trim: Trim{Enabled: true}},
+	}
+	for i, opts := range different {
+		if opts.Fingerprint() == base.Fingerprint() {
+			t.Errorf("different[%d].Fingerprint() = %q, want a change", i, opts.Fingerprint())
+		}
+	}
+
+	// Source format aliases are merged
+	tif := Options{Policy: Policy{Targets: map[string]Format{"tif": FormatJPEG, "jpg": FormatPNG}}}
+	tiff := Options{Policy: Policy{Targets: map[string]Format{"tiff": FormatJPEG, "jpeg": FormatPNG}}}
+	if tif.Fingerprint() != tiff.Fingerprint() {
+		t.Errorf("aliases: Fingerprint() = %q and %q, want equal", tif.Fingerprint(), tiff.Fingerprint())
+	}
+
+	// Map order does not matter
+	targets := map[string]Format{"webp": FormatPNG, "bmp": FormatPNG, "gif": FormatJPEG, "tiff": FormatJPEG}
+	want := Options{Policy: Policy{Targets: targets}}.Fingerprint()
+	for i := 0; i < 10; i++ {
+		if got := (Options{Policy: Policy{Targets: targets}}).Fingerprint(); got != want {
+			t.Fatalf("Fingerprint() = %q, then %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+)
+
+// pipeline applies the configured conversion stages to single pages:
+// decode, trim, split, resize, then encode in the target format.
+type pipeline struct {
+	policy Policy
+	trim   Trim
+	split  Split
+	resize Resize
+	log    *logger
+}
+
+// page is one output page planned from a source image.
+// A page with FormatKeep, partWhole and no trim is the source file, unchanged.
+type page struct {
+	name   string // Archive entry name, before FormatAuto is resolved
+	format Format
+	trim   image.Rectangle // Region of the source left after trimming; empty means all of it
+	part   part
+}
+
+// unchanged reports whether the page is the source file as-is.
+func (pg page) unchanged() bool {
+	return pg.format == FormatKeep && pg.trim.Empty() && pg.part == partWhole
+}
+
+// newPipeline builds a pipeline from opts, filling in defaults.
+func newPipeline(opts Options) *pipeline {
+	return &pipeline{
+		policy: opts.Policy.withDefaults(),
+		trim:   opts.Trim,
+		split:  opts.Split,
+		resize: opts.Resize,
+		log:    &logger{w: opts.Verbose},
+	}
+}
+
+// plan returns the output pages for a source image, in reading order.
+// Pages the policy keeps are still re-encoded in their own format when
+// another stage has to modify them.
+//
+// Without trimming, pages are planned from the image header alone. With
+// trimming the image is decoded to find its margins, and the decoded
+// image is returned so it can be rendered without decoding it again;
+// otherwise the returned image is nil.
+func (p *pipeline) plan(img chapter.ImageFile) ([]page, image.Image) {
+	whole := page{name: img.Name, format: p.policy.target(img.Name)}
+	if !p.trim.Enabled && !p.split.Enabled && !p.resize.enabled() {
+		return []page{whole.named()}, nil
+	}
+
+	// Unreadable images are left alone; the page is archived unchanged
+	if p.trim.Enabled {
+		src, err := decodeImage(img)
+		if err != nil {
+			return []page{whole.named()}, nil
+		}
+
+		bounds := src.Bounds()
+		if trimmed := p.trim.bounds(src); trimmed != bounds {
+			whole.trim = trimmed
+			p.log.printf("  Trim: %s %dx%d -> %dx%d", img.Name, bounds.Dx(), bounds.Dy(), trimmed.Dx(), trimmed.Dy())
+		}
+		return p.layout(img, whole, bounds), src
+	}
+
+	cfg, err := decodeImageConfig(img)
+	if err != nil {
+		return []page{whole.named()}, nil
+	}
+	return p.layout(img, whole, image.Rect(0, 0, cfg.Width, cfg.Height)), nil
+}
+
+// layout splits and sizes the whole page of img, whose source image
+// covers bounds.
+func (p *pipeline) layout(img chapter.ImageFile, whole page, bounds image.Rectangle) []page {
+	// Re-encode in the native format when no conversion is planned
+	modified := whole.format
+	if modified == FormatKeep {
+		modified = nativeFormat(img.Name)
+	}
+
+	rect := bounds
+	if !whole.trim.Empty() {
+		rect = whole.trim
+		whole.format = modified
+	}
+
+	if p.split.isSpread(rect.Dx(), rect.Dy()) {
+		var pages []page
+		if p.split.KeepSpread {
+			if _, _, changed := p.resize.fit(rect.Dx(), rect.Dy()); changed {
+				whole.format = modified
+			}
+			pages = append(pages, whole.named())
+		}
+		for n, half := range p.split.halves() {
+			pages = append(pages, page{name: halfName(img.Name, n+1), format: modified, trim: whole.trim, part: half}.named())
+		}
+		p.log.printf("  Split: %s (%dx%d) into 2 pages", img.Name, rect.Dx(), rect.Dy())
+		return pages
+	}
+
+	if _, _, changed := p.resize.fit(rect.Dx(), rect.Dy()); changed {
+		whole.format = modified
+	}
+	return []page{whole.named()}
+}
+
+// named returns the page with its name's extension matching its format.
+func (pg page) named() page {
+	if pg.format != FormatKeep && pg.format != FormatAuto {
+		pg.name = renamed(pg.name, pg.format)
+	}
+	return pg
+}
+
+// checkNames returns an error if two planned pages would be stored under
+// the same entry name (ignoring case), e.g. 1.bmp and 1.gif both converted
+// to 1.png, or a converted page and a page passed through unchanged. A
+// FormatAuto page may become either PNG or JPEG, so both of its names are
+// reserved.
+// plans[i] holds the pages planned from images[i].
+func checkNames(images []chapter.ImageFile, plans [][]page) error {
+	owners := make(map[string]int)
+	for i, pages := range plans {
+		for _, pg := range pages {
+			names := []string{pg.name}
+			if pg.format == FormatAuto {
+				names = []string{renamed(pg.name, FormatPNG), renamed(pg.name, FormatJPEG)}
+			}
+			for _, name := range names {
+				key := strings.ToLower(name)
+				if owner, ok := owners[key]; ok && owner != i {
+					return fmt.Errorf("pages %s and %s would both be stored as %s", images[owner].Name, images[i].Name, name)
+				}
+				owners[key] = i
+			}
+		}
+	}
+	return nil
+}
+
+// render applies the enabled stages for pg to the decoded source image.
+// Returns the image to encode and its concrete format (FormatAuto is
+// resolved here).
+func (p *pipeline) render(src image.Image, pg page) (image.Image, Format) {
+	img := src
+	if !pg.trim.Empty() {
+		img = crop(img, pg.trim)
+	}
+	img = pg.part.crop(img)
+
+	if p.resize.enabled() {
+		before := img.Bounds()
+		img = p.resize.apply(img)
+		if after := img.Bounds(); after != before {
+			p.log.printf("  Resize: %s %dx%d -> %dx%d", pg.name, before.Dx(), before.Dy(), after.Dx(), after.Dy())
+		}
+	}
+
+	format := pg.format
+	if format == FormatAuto {
+		var reason string
+		format, img, reason = resolveAuto(img)
+		p.log.printf("  Auto: %s -> %s (%s)", pg.name, renamed(pg.name, format), reason)
+	}
+
+	return img, format
+}
+
+// renderFile decodes the image file and renders pg from it.
+func (p *pipeline) renderFile(file chapter.ImageFile, pg page) (image.Image, Format, error) {
+	src, err := decodeImage(file)
+	if err != nil {
+		return nil, "", err
+	}
+	img, format := p.render(src, pg)
+	return img, format, nil
+}
+
+// config returns the dimensions page pg will have once rendered from file.
+func (p *pipeline) config(file chapter.ImageFile, pg page) (image.Config, error) {
+	cfg, err := decodeImageConfig(file)
+	if err != nil {
+		return image.Config{}, err
+	}
+	rect := image.Rect(0, 0, cfg.Width, cfg.Height)
+	if !pg.trim.Empty() {
+		rect = pg.trim
+	}
+	rect = pg.part.bounds(rect)
+	cfg.Width, cfg.Height, _ = p.resize.fit(rect.Dx(), rect.Dy())
+	return cfg, nil
+}
+
+// nativeFormat is the format a kept page is re-encoded in when another
+// stage has to modify it: JPEG stays JPEG, everything else becomes PNG.
+func nativeFormat(filename string) Format {
+	if sourceFormat(filename) == "jpeg" {
+		return FormatJPEG
+	}
+	return FormatPNG
+}
+
+// decodeImageConfig reads the header of the image file (or archive entry).
+func decodeImageConfig(file chapter.ImageFile) (image.Config, error) {
+	f, err := file.Open()
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
@@ -0,0 +1,239 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/bmp"  // Register BMP decoder
+	_ "golang.org/x/image/tiff" // Register TIFF decoder
+	_ "golang.org/x/image/webp" // Register WebP decoder
+
+	"manga2cbz/internal/chapter"
+)
+
+// Format is a target image encoding.
+type Format string
+
+// Target formats.
+const (
+	FormatKeep Format = "keep" // Pass the source file through unchanged
+	FormatPNG  Format = "png"
+	FormatJPEG Format = "jpeg"
+)
+
+// DefaultJPEGQuality is used when Policy.JPEGQuality is zero.
+const DefaultJPEGQuality = 90
+
+// sourceFormats maps lowercase file extensions to source format names.
+var sourceFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".webp": "webp",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+	".gif":  "gif",
+}
+
+// Policy decides which source formats are converted, and to what.
+// The zero value is equivalent to DefaultPolicy.
+type Policy struct {
+	Targets        map[string]Format    // Source format ("webp", "bmp", "tiff", "gif", ...) to target
+	JPEGQuality    int                  // 1-100; 0 means DefaultJPEGQuality
+	PNGCompression png.CompressionLevel // png.DefaultCompression, BestSpeed, ...
+}
+
+// DefaultPolicy converts WebP to PNG and keeps everything else,
+// matching the historical behavior.
+func DefaultPolicy() Policy {
+	return Policy{Targets: map[string]Format{"webp": FormatPNG}}
+}
+
+// ParseTargets parses a comma-separated list of source=target pairs,
+// such as "webp=jpeg,bmp=png,gif=keep".
+func ParseTargets(s string) (map[string]Format, error) {
+	targets := make(map[string]Format)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		src, dst, ok := strings.Cut(pair, "=")
+		src = normalizeSource(src)
+		if !ok || !isSourceFormat(src) {
+			return nil, fmt.Errorf("invalid conversion %q, expected e.g. webp=png", pair)
+		}
+
+		format, err := ParseFormat(dst)
+		if err != nil {
+			return nil, err
+		}
+		targets[src] = format
+	}
+	return targets, nil
+}
+
+// ParseFormat parses a target format name: keep, png, jpeg, jpg or auto.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "keep", "none":
+		return FormatKeep, nil
+	case "png":
+		return FormatPNG, nil
+	case "jpeg", "jpg":
+		return FormatJPEG, nil
+	case "auto":
+		return FormatAuto, nil
+	}
+	return "", fmt.Errorf("unknown target format %q (want keep, png, jpeg or auto)", s)
+}
+
+// ParsePNGCompression parses a PNG compression level name:
+// default, none, fast or best.
+func ParsePNGCompression(s string) (png.CompressionLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "default", "":
+		return png.DefaultCompression, nil
+	case "none":
+		return png.NoCompression, nil
+	case "fast":
+		return png.BestSpeed, nil
+	case "best":
+		return png.BestCompression, nil
+	}
+	return 0, fmt.Errorf("unknown PNG compression %q (want default, none, fast or best)", s)
+}
+
+// normalizeSource lowercases a source format name and accepts aliases.
+func normalizeSource(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "jpg":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	}
+	return s
+}
+
+// isSourceFormat reports whether name is a known source format.
+func isSourceFormat(name string) bool {
+	for _, f := range sourceFormats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceFormat returns the source format of a file name from its extension,
+// or "" if the extension is unknown.
+func sourceFormat(filename string) string {
+	return sourceFormats[strings.ToLower(filepath.Ext(filename))]
+}
+
+// withDefaults fills in zero values and normalizes the targets.
+func (p Policy) withDefaults() Policy {
+	if p.Targets == nil {
+		p.Targets = DefaultPolicy().Targets
+	}
+	p.Targets = normalizeTargets(p.Targets)
+	if p.JPEGQuality == 0 {
+		p.JPEGQuality = DefaultJPEGQuality
+	}
+	return p
+}
+
+// normalizeTargets returns targets keyed by canonical source format
+// names, so that aliases such as jpg and tif take effect. Entries that
+// keep the source as it is (keep, or a format mapped to itself) are
+// dropped. Where an alias and the canonical name are both present, the
+// canonical name wins.
+func normalizeTargets(targets map[string]Format) map[string]Format {
+	normalized := make(map[string]Format, len(targets))
+	for name, f := range targets {
+		src := normalizeSource(name)
+		if _, ok := targets[src]; ok && src != name {
+			continue
+		}
+		if f == FormatKeep || string(f) == src {
+			continue
+		}
+		normalized[src] = f
+	}
+	return normalized
+}
+
+// target returns the format a file should be converted to, or FormatKeep
+// if it should be passed through. Converting to the source's own format
+// is treated as FormatKeep.
+func (p Policy) target(filename string) Format {
+	src := sourceFormat(filename)
+	format, ok := p.Targets[src]
+	if !ok || string(format) == src {
+		return FormatKeep
+	}
+	return format
+}
+
+// extension returns the file extension used for a target format.
+func (f Format) extension() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// renamed returns filename with its extension replaced for format f.
+func renamed(filename string, f Format) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + f.extension()
+}
+
+// decodeImage decodes the image file (or archive entry) using the
+// registered decoders.
+func decodeImage(file chapter.ImageFile) (image.Image, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// encode writes img to w in format f using the policy's settings.
+func (p Policy) encode(w io.Writer, img image.Image, f Format) error {
+	switch f {
+	case FormatPNG:
+		encoder := png.Encoder{CompressionLevel: p.PNGCompression}
+		return encoder.Encode(w, img)
+	case FormatJPEG:
+		return jpeg.Encode(w, flattenAlpha(img), &jpeg.Options{Quality: p.JPEGQuality})
+	}
+	return fmt.Errorf("cannot encode to format %q", f)
+}
+
+// flattenAlpha composites images with transparency onto white,
+// since JPEG cannot store alpha and would otherwise turn it black.
+func flattenAlpha(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, img, bounds.Min, draw.Over)
+	return flat
+}
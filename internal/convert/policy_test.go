@@ -0,0 +1,245 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("webp=jpeg, BMP=png,tif=keep,gif=jpg")
+	if err != nil {
+		t.Fatalf("ParseTargets() error = %v", err)
+	}
+
+	want := map[string]Format{
+		"webp": FormatJPEG,
+		"bmp":  FormatPNG,
+		"tiff": FormatKeep,
+		"gif":  FormatJPEG,
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("ParseTargets() = %v, want %v", targets, want)
+	}
+	for src, format := range want {
+		if targets[src] != format {
+			t.Errorf("targets[%q] = %q, want %q", src, targets[src], format)
+		}
+	}
+}
+
+func TestParseTargets_Errors(t *testing.T) {
+	for _, s := range []string{"webp", "psd=png", "webp=avif", "=png"} {
+		if _, err := ParseTargets(s); err == nil {
+			t.Errorf("ParseTargets(%q) should return an error", s)
+		}
+	}
+}
+
+func TestParsePNGCompression(t *testing.T) {
+	tests := []struct {
+		in   string
+		want png.CompressionLevel
+	}{
+		{"default", png.DefaultCompression},
+		{"none", png.NoCompression},
+		{"fast", png.BestSpeed},
+		{"BEST", png.BestCompression},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePNGCompression(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePNGCompression(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParsePNGCompression("max"); err == nil {
+		t.Error("ParsePNGCompression(\"max\") should return an error")
+	}
+}
+
+func TestPolicyTarget(t *testing.T) {
+	policy := Policy{Targets: map[string]Format{
+		"webp": FormatJPEG,
+		"png":  FormatPNG,
+		"bmp":  FormatKeep,
+		"TIF":  FormatJPEG,
+		"jpg":  FormatPNG,
+		"jpeg": FormatKeep,
+	}}.withDefaults()
+
+	tests := []struct {
+		filename string
+		want     Format
+	}{
+		{"a.webp", FormatJPEG},
+		{"a.WEBP", FormatJPEG},
+		{"a.png", FormatKeep}, // Same format is a no-op
+		{"a.bmp", FormatKeep},
+		{"a.gif", FormatKeep},  // Not in the policy
+		{"a.tiff", FormatJPEG}, // Alias
+		{"a.jpg", FormatKeep},  // Canonical name wins over the alias
+		{"a.txt", FormatKeep},
+	}
+
+	for _, tt := range tests {
+		if got := policy.target(tt.filename); got != tt.want {
+			t.Errorf("target(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestConvertImages_JPEGPolicy(t *testing.T) {
+	tempDir := t.TempDir()
+
+	webpPath := filepath.Join(tempDir, "01.webp")
+	createTestFile(t, webpPath, minimalWebP)
+	bmpPath := filepath.Join(tempDir, "02.bmp")
+	writeImage(t, bmpPath, func(f *os.File, img image.Image) error { return bmp.Encode(f, img) })
+	tiffPath := filepath.Join(tempDir, "03.tiff")
+	writeImage(t, tiffPath, func(f *os.File, img image.Image) error { return tiff.Encode(f, img, nil) })
+
+	images := []chapter.ImageFile{
+		{Path: webpPath, Name: "01.webp"},
+		{Path: bmpPath, Name: "02.bmp"},
+		{Path: tiffPath, Name: "03.tiff"},
+	}
+
+	opts := Options{Policy: Policy{
+		Targets:     map[string]Format{"webp": FormatJPEG, "bmp": FormatPNG, "tiff": FormatJPEG},
+		JPEGQuality: 80,
+	}}
+
+	result, cleanup, err := ConvertImages(images, opts)
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	expected := []string{"01.jpg", "02.png", "03.jpg"}
+	for i, img := range result {
+		if img.Name != expected[i] {
+			t.Errorf("result[%d].Name = %q, want %q", i, img.Name, expected[i])
+		}
+	}
+
+	verifyJPEG(t, result[0].Path)
+	verifyPNG(t, result[1].Path)
+	verifyJPEG(t, result[2].Path)
+}
+
+func TestConvertImages_NameCollisions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]func(*os.File, image.Image) error{
+		"1.bmp": func(f *os.File, img image.Image) error { return bmp.Encode(f, img) },
+		"1.gif": func(f *os.File, img image.Image) error { return gif.Encode(f, img, nil) },
+		"1.png": func(f *os.File, img image.Image) error { return png.Encode(f, img) },
+		"2.gif": func(f *os.File, img image.Image) error { return gif.Encode(f, img, nil) },
+	}
+	for name, encode := range files {
+		writeImage(t, filepath.Join(tempDir, name), encode)
+	}
+	pages := func(names ...string) []chapter.ImageFile {
+		var images []chapter.ImageFile
+		for _, name := range names {
+			images = append(images, chapter.ImageFile{Path: filepath.Join(tempDir, name), Name: name})
+		}
+		return images
+	}
+
+	tests := []struct {
+		name    string
+		images  []chapter.ImageFile
+		targets map[string]Format
+		trim    bool
+		wantErr bool
+	}{
+		{"distinct names", pages("1.bmp", "2.gif"), map[string]Format{"bmp": FormatPNG, "gif": FormatPNG}, false, false},
+		{"two converted pages", pages("1.bmp", "1.gif"), map[string]Format{"bmp": FormatPNG, "gif": FormatPNG}, false, true},
+		{"converted and unchanged page", pages("1.bmp", "1.png"), map[string]Format{"bmp": FormatPNG}, false, true},
+		{"auto page", pages("1.png", "1.gif"), map[string]Format{"gif": FormatAuto}, false, true},
+		{"trimmed pages", pages("1.bmp", "1.gif"), map[string]Format{"bmp": FormatPNG, "gif": FormatPNG}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Policy: Policy{Targets: tt.targets}, Trim: Trim{Enabled: tt.trim}}
+
+			result, cleanup, err := ConvertImages(tt.images, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertImages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				defer cleanup()
+				if result[0].Path == result[1].Path {
+					t.Errorf("pages share the temp file %s", result[0].Path)
+				}
+			}
+
+			if _, err := StreamImages(tt.images, opts); (err != nil) != tt.wantErr {
+				t.Errorf("StreamImages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncode_JPEGFlattensAlphaOnWhite(t *testing.T) {
+	// Fully transparent image would turn black without flattening
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+
+	var buf bytes.Buffer
+	if err := DefaultPolicy().withDefaults().encode(&buf, img, FormatJPEG); err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("output is not a valid JPEG: %v", err)
+	}
+	r, g, b, _ := decoded.At(1, 1).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("transparent pixel encoded as (%d,%d,%d), want white", r>>8, g>>8, b>>8)
+	}
+}
+
+// writeImage encodes a small opaque test image with the given encoder.
+func writeImage(t *testing.T, path string, encode func(*os.File, image.Image) error) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if err := encode(f, img); err != nil {
+		t.Fatalf("failed to encode %s: %v", path, err)
+	}
+}
+
+func verifyJPEG(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open JPEG file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if _, err := jpeg.Decode(f); err != nil {
+		t.Errorf("file %s is not a valid JPEG: %v", path, err)
+	}
+}
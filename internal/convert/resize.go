@@ -0,0 +1,107 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize limits page dimensions. Pages larger than any limit are scaled
+// down, preserving aspect ratio, to fit within all of them. Pages are
+// never scaled up. Zero fields mean no limit.
+type Resize struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64 // Width times height
+}
+
+// enabled reports whether any limit is set.
+func (r Resize) enabled() bool {
+	return r.MaxWidth > 0 || r.MaxHeight > 0 || r.MaxPixels > 0
+}
+
+// ParseResize parses a size limit: "WxH", "Wx" or "xH" for maximum
+// width and/or height, or "NMP" for a pixel count in megapixels
+// (e.g. "8MP").
+func ParseResize(s string) (Resize, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+
+	if mp, ok := strings.CutSuffix(s, "mp"); ok {
+		megapixels, err := strconv.ParseFloat(mp, 64)
+		if err != nil || megapixels <= 0 {
+			return Resize{}, fmt.Errorf("invalid pixel limit %q, expected e.g. 8MP", s)
+		}
+		return Resize{MaxPixels: int64(megapixels * 1e6)}, nil
+	}
+
+	w, h, ok := strings.Cut(s, "x")
+	if !ok || (w == "" && h == "") {
+		return Resize{}, fmt.Errorf("invalid size limit %q, expected e.g. 1600x2400", s)
+	}
+
+	var r Resize
+	var err error
+	if w != "" {
+		if r.MaxWidth, err = strconv.Atoi(w); err != nil || r.MaxWidth <= 0 {
+			return Resize{}, fmt.Errorf("invalid width in size limit %q", s)
+		}
+	}
+	if h != "" {
+		if r.MaxHeight, err = strconv.Atoi(h); err != nil || r.MaxHeight <= 0 {
+			return Resize{}, fmt.Errorf("invalid height in size limit %q", s)
+		}
+	}
+	return r, nil
+}
+
+// fit returns the dimensions of a width x height page after applying the
+// limits, and whether they differ from the original.
+func (r Resize) fit(width, height int) (int, int, bool) {
+	if width <= 0 || height <= 0 {
+		return width, height, false
+	}
+
+	scale := 1.0
+	if r.MaxWidth > 0 {
+		scale = math.Min(scale, float64(r.MaxWidth)/float64(width))
+	}
+	if r.MaxHeight > 0 {
+		scale = math.Min(scale, float64(r.MaxHeight)/float64(height))
+	}
+	if r.MaxPixels > 0 {
+		scale = math.Min(scale, math.Sqrt(float64(r.MaxPixels)/(float64(width)*float64(height))))
+	}
+	if scale >= 1 {
+		return width, height, false
+	}
+
+	// Round down so the result never exceeds a limit; the epsilon keeps
+	// exact fits (e.g. 3200 * 0.5) from losing a pixel to float error
+	newWidth := max(1, int(float64(width)*scale+1e-9))
+	newHeight := max(1, int(float64(height)*scale+1e-9))
+	return newWidth, newHeight, true
+}
+
+// apply scales img down to fit the limits using Catmull-Rom resampling.
+// Returns img unchanged if it already fits. Grayscale stays grayscale.
+func (r Resize) apply(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height, changed := r.fit(bounds.Dx(), bounds.Dy())
+	if !changed {
+		return img
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	var dst draw.Image
+	if _, ok := img.(*image.Gray); ok {
+		dst = image.NewGray(rect)
+	} else {
+		dst = image.NewRGBA(rect)
+	}
+	draw.CatmullRom.Scale(dst, rect, img, bounds, draw.Src, nil)
+	return dst
+}
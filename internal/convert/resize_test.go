@@ -0,0 +1,159 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestResizeFit(t *testing.T) {
+	tests := []struct {
+		name          string
+		resize        Resize
+		width, height int
+		wantW, wantH  int
+		wantChanged   bool
+	}{
+		{"max height", Resize{MaxHeight: 2000}, 3000, 4000, 1500, 2000, true},
+		{"max width", Resize{MaxWidth: 1000}, 2000, 3000, 1000, 1500, true},
+		{"both, height binds", Resize{MaxWidth: 1600, MaxHeight: 2000}, 3000, 6000, 1000, 2000, true},
+		{"pixel count", Resize{MaxPixels: 1000000}, 2000, 2000, 1000, 1000, true},
+		{"already fits", Resize{MaxWidth: 2000, MaxHeight: 3000}, 1000, 1500, 1000, 1500, false},
+		{"never upscales", Resize{MaxHeight: 5000}, 100, 200, 100, 200, false},
+		{"no limits", Resize{}, 8000, 8000, 8000, 8000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, changed := tt.resize.fit(tt.width, tt.height)
+			if w != tt.wantW || h != tt.wantH || changed != tt.wantChanged {
+				t.Errorf("fit(%d, %d) = %d, %d, %v; want %d, %d, %v",
+					tt.width, tt.height, w, h, changed, tt.wantW, tt.wantH, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Resize
+	}{
+		{"1600x2400", Resize{MaxWidth: 1600, MaxHeight: 2400}},
+		{"1600x", Resize{MaxWidth: 1600}},
+		{"x2400", Resize{MaxHeight: 2400}},
+		{"8MP", Resize{MaxPixels: 8000000}},
+		{"0.5mp", Resize{MaxPixels: 500000}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseResize(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "x", "1600", "ax100", "100x-1", "0mp", "bigmp"} {
+		if _, err := ParseResize(bad); err == nil {
+			t.Errorf("ParseResize(%q) should return an error", bad)
+		}
+	}
+}
+
+func TestResizeApply_KeepsGray(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 400, 800))
+	out := Resize{MaxHeight: 200}.apply(img)
+
+	if out.Bounds().Dx() != 100 || out.Bounds().Dy() != 200 {
+		t.Errorf("resized to %v, want 100x200", out.Bounds())
+	}
+	if _, ok := out.(*image.Gray); !ok {
+		t.Errorf("resized gray image is %T, want *image.Gray", out)
+	}
+}
+
+func TestConvertImages_ResizesAnyFormat(t *testing.T) {
+	tempDir := t.TempDir()
+
+	bigPNG := filepath.Join(tempDir, "01.png")
+	writePNG(t, bigPNG, grayImage(400, 600))
+	bigJPEG := filepath.Join(tempDir, "02.jpg")
+	writeImage(t, bigJPEG, func(f *os.File, _ image.Image) error {
+		return jpeg.Encode(f, photoImage(300, 600), nil)
+	})
+	small := filepath.Join(tempDir, "03.png")
+	writePNG(t, small, grayImage(100, 150))
+
+	images := []chapter.ImageFile{
+		{Path: bigPNG, Name: "01.png"},
+		{Path: bigJPEG, Name: "02.jpg"},
+		{Path: small, Name: "03.png"},
+	}
+
+	var log bytes.Buffer
+	result, cleanup, err := ConvertImages(images, Options{Resize: Resize{MaxHeight: 300}, Verbose: &log})
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	// Formats are kept; only oversized pages are touched
+	wantNames := []string{"01.png", "02.jpg", "03.png"}
+	wantSizes := [][2]int{{200, 300}, {150, 300}, {100, 150}}
+	for i, img := range result {
+		if img.Name != wantNames[i] {
+			t.Errorf("result[%d].Name = %q, want %q", i, img.Name, wantNames[i])
+		}
+		cfg, err := decodeImageConfig(img)
+		if err != nil {
+			t.Fatalf("failed to read result[%d]: %v", i, err)
+		}
+		if cfg.Width != wantSizes[i][0] || cfg.Height != wantSizes[i][1] {
+			t.Errorf("result[%d] is %dx%d, want %dx%d", i, cfg.Width, cfg.Height, wantSizes[i][0], wantSizes[i][1])
+		}
+	}
+	if result[2].Path != small {
+		t.Error("page within limits should not be re-encoded")
+	}
+	if !strings.Contains(log.String(), "Resize: 01.png 400x600 -> 200x300") {
+		t.Errorf("verbose log missing resize:\n%s", log.String())
+	}
+}
+
+func TestStreamImages_ResizeReportsDimensions(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "01.png")
+	writePNG(t, path, grayImage(400, 600))
+
+	result, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "01.png"}}, Options{Resize: Resize{MaxWidth: 100}})
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+
+	sized, ok := result[0].Source.(interface{ Config() (image.Config, error) })
+	if !ok {
+		t.Fatal("resized source should report its dimensions")
+	}
+	cfg, err := sized.Config()
+	if err != nil || cfg.Width != 100 || cfg.Height != 150 {
+		t.Errorf("Config() = %dx%d, %v; want 100x150", cfg.Width, cfg.Height, err)
+	}
+
+	var buf bytes.Buffer
+	if err := result[0].Source.Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, _, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatalf("encoded page is invalid: %v", err)
+	}
+	if decoded.Bounds().Dx() != 100 || decoded.Bounds().Dy() != 150 {
+		t.Errorf("encoded page is %v, want 100x150", decoded.Bounds())
+	}
+}
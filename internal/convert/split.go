@@ -0,0 +1,96 @@
+package convert
+
+import (
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSpreadRatio is the width/height ratio at or above which a page
+// is treated as a two-page spread when Split.MinRatio is zero. It is
+// clearly landscape, so square and near-square pages are left whole.
+const DefaultSpreadRatio = 1.2
+
+// Split configures splitting of double-page spreads into single pages.
+type Split struct {
+	Enabled     bool
+	MinRatio    float64 // Width/height ratio that marks a spread; 0 means DefaultSpreadRatio
+	RightToLeft bool    // Emit the right half first, for manga
+	KeepSpread  bool    // Also keep the original spread, before its halves
+}
+
+// part selects the region of a source image shown on an output page.
+type part int
+
+const (
+	partWhole part = iota
+	partLeft
+	partRight
+)
+
+// isSpread reports whether a width x height page should be split.
+func (s Split) isSpread(width, height int) bool {
+	if !s.Enabled || height <= 0 {
+		return false
+	}
+	ratio := s.MinRatio
+	if ratio <= 0 {
+		ratio = DefaultSpreadRatio
+	}
+	return float64(width)/float64(height) >= ratio
+}
+
+// halves returns the two halves of a spread in reading order.
+func (s Split) halves() [2]part {
+	if s.RightToLeft {
+		return [2]part{partRight, partLeft}
+	}
+	return [2]part{partLeft, partRight}
+}
+
+// halfName names the n-th half (1 or 2, in reading order) of a spread.
+// "05.jpg" becomes "05_1.jpg" and "05_2.jpg", which natural sort places
+// after the spread itself and before "06.jpg".
+func halfName(filename string, n int) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "_" + string(rune('0'+n)) + ext
+}
+
+// bounds returns the region of r covered by the part.
+// For odd widths the extra column goes to the right half.
+func (p part) bounds(r image.Rectangle) image.Rectangle {
+	mid := r.Min.X + r.Dx()/2
+	switch p {
+	case partLeft:
+		return image.Rect(r.Min.X, r.Min.Y, mid, r.Max.Y)
+	case partRight:
+		return image.Rect(mid, r.Min.Y, r.Max.X, r.Max.Y)
+	}
+	return r
+}
+
+// crop returns the part of img, sharing its pixels where possible.
+func (p part) crop(img image.Image) image.Image {
+	if p == partWhole {
+		return img
+	}
+	return crop(img, p.bounds(img.Bounds()))
+}
+
+// crop returns the region rect of img, sharing its pixels where possible.
+func crop(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+
+	// Fallback for image types without SubImage
+	dst := image.NewRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			dst.Set(x, y, img.At(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,199 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/sort"
+)
+
+// spreadImage returns a w x h gray image whose left half is black and
+// right half is white, so the order of the halves can be checked.
+func spreadImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	return img
+}
+
+// firstPixel returns the gray value of the top-left pixel of the image at path.
+func firstPixel(t *testing.T, path string) uint8 {
+	t.Helper()
+	img, err := decodeImage(chapter.ImageFile{Path: path})
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+	bounds := img.Bounds()
+	return color.GrayModel.Convert(img.At(bounds.Min.X, bounds.Min.Y)).(color.Gray).Y
+}
+
+func TestSplitIsSpread(t *testing.T) {
+	tests := []struct {
+		name          string
+		split         Split
+		width, height int
+		want          bool
+	}{
+		{"landscape", Split{Enabled: true}, 2000, 1500, true},
+		{"square", Split{Enabled: true}, 1500, 1500, false},
+		{"near square", Split{Enabled: true}, 1600, 1500, false},
+		{"portrait", Split{Enabled: true}, 1000, 1500, false},
+		{"below custom ratio", Split{Enabled: true, MinRatio: 1.3}, 1800, 1500, false},
+		{"above custom ratio", Split{Enabled: true, MinRatio: 1.3}, 2000, 1500, true},
+		{"disabled", Split{}, 2000, 1500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.split.isSpread(tt.width, tt.height); got != tt.want {
+				t.Errorf("isSpread(%d, %d) = %v, want %v", tt.width, tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHalfName_SortsBetweenNeighbours(t *testing.T) {
+	names := []string{"06.jpg", halfName("05.jpg", 2), "05.jpg", halfName("05.jpg", 1), "04.jpg"}
+	sort.Natural(names)
+
+	want := []string{"04.jpg", "05.jpg", "05_1.jpg", "05_2.jpg", "06.jpg"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("sorted names = %v, want %v", names, want)
+			break
+		}
+	}
+}
+
+func TestPartBounds_OddWidth(t *testing.T) {
+	r := image.Rect(0, 0, 301, 200)
+	if got := partLeft.bounds(r); got.Dx() != 150 {
+		t.Errorf("left half width = %d, want 150", got.Dx())
+	}
+	if got := partRight.bounds(r); got.Dx() != 151 {
+		t.Errorf("right half width = %d, want 151", got.Dx())
+	}
+	if got := partWhole.bounds(r); got != r {
+		t.Errorf("whole bounds = %v, want %v", got, r)
+	}
+}
+
+func TestConvertImages_SplitSpreads(t *testing.T) {
+	tests := []struct {
+		name       string
+		split      Split
+		wantNames  []string
+		wantPixels []uint8 // Top-left pixel of each page; 0 is the left half
+	}{
+		{
+			name:       "left to right",
+			split:      Split{Enabled: true},
+			wantNames:  []string{"01.png", "02_1.png", "02_2.png", "03.png"},
+			wantPixels: []uint8{0, 0, 255, 0},
+		},
+		{
+			name:       "right to left",
+			split:      Split{Enabled: true, RightToLeft: true},
+			wantNames:  []string{"01.png", "02_1.png", "02_2.png", "03.png"},
+			wantPixels: []uint8{0, 255, 0, 0},
+		},
+		{
+			name:       "keep spread",
+			split:      Split{Enabled: true, RightToLeft: true, KeepSpread: true},
+			wantNames:  []string{"01.png", "02.png", "02_1.png", "02_2.png", "03.png"},
+			wantPixels: []uint8{0, 0, 255, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			var images []chapter.ImageFile
+			for _, p := range []struct {
+				name string
+				img  image.Image
+			}{
+				{"01.png", grayImage(100, 150)},
+				{"02.png", spreadImage(300, 150)},
+				{"03.png", grayImage(100, 150)},
+			} {
+				path := filepath.Join(tempDir, p.name)
+				writePNG(t, path, p.img)
+				images = append(images, chapter.ImageFile{Path: path, Name: p.name})
+			}
+
+			var log bytes.Buffer
+			result, cleanup, err := ConvertImages(images, Options{Split: tt.split, Verbose: &log})
+			if err != nil {
+				t.Fatalf("ConvertImages returned error: %v", err)
+			}
+			defer cleanup()
+
+			if len(result) != len(tt.wantNames) {
+				t.Fatalf("result length = %d, want %d", len(result), len(tt.wantNames))
+			}
+			for i, img := range result {
+				if img.Name != tt.wantNames[i] {
+					t.Errorf("result[%d].Name = %q, want %q", i, img.Name, tt.wantNames[i])
+				}
+				if got := firstPixel(t, img.Path); got != tt.wantPixels[i] {
+					t.Errorf("result[%d] starts with %d, want %d", i, got, tt.wantPixels[i])
+				}
+			}
+
+			// Portrait pages are passed through untouched
+			if result[0] != images[0] {
+				t.Errorf("result[0] = %+v, want %+v", result[0], images[0])
+			}
+			if !strings.Contains(log.String(), "Split: 02.png (300x150) into 2 pages") {
+				t.Errorf("verbose log missing split:\n%s", log.String())
+			}
+		})
+	}
+}
+
+func TestStreamImages_SplitReportsHalfDimensions(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "01.png")
+	writePNG(t, path, spreadImage(400, 300))
+
+	opts := Options{Split: Split{Enabled: true}, Resize: Resize{MaxHeight: 150}}
+	result, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "01.png"}}, opts)
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("result length = %d, want 2", len(result))
+	}
+
+	for i, img := range result {
+		sized, ok := img.Source.(interface{ Config() (image.Config, error) })
+		if !ok {
+			t.Fatalf("result[%d] should report its dimensions", i)
+		}
+		cfg, err := sized.Config()
+		if err != nil || cfg.Width != 100 || cfg.Height != 150 {
+			t.Errorf("result[%d].Config() = %dx%d, %v; want 100x150", i, cfg.Width, cfg.Height, err)
+		}
+
+		var buf bytes.Buffer
+		if err := img.Source.Encode(&buf); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		decoded, _, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("encoded page is invalid: %v", err)
+		}
+		if decoded.Bounds().Dx() != 100 || decoded.Bounds().Dy() != 150 {
+			t.Errorf("result[%d] encoded as %v, want 100x150", i, decoded.Bounds())
+		}
+	}
+}
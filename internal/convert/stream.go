@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"image"
+	"io"
+
+	"manga2cbz/internal/chapter"
+)
+
+// StreamImages returns images with every page that needs converting,
+// trimming, splitting or resizing replaced by pages that are transcoded on demand,
+// while the archive entry is being written. Unlike ConvertImages, no
+// temporary files are created and there is nothing to clean up. Pages are
+// transcoded one at a time as the archive is written, so opts.Workers and
+// opts.MemoryBudget do not apply. Other pages are passed through unchanged.
+//
+// Pages targeted at FormatAuto are decoded once here to choose their
+// format (and so their entry name), and decoded again when written.
+// The same goes for every page when trimming is enabled, to find its
+// margins. Returns an error, before any page is rendered, if two pages
+// would end up with the same name (see checkNames).
+func StreamImages(images []chapter.ImageFile, opts Options) ([]chapter.ImageFile, error) {
+	pipe := newPipeline(opts)
+
+	// Pages are rendered again while writing; don't repeat the log lines
+	quiet := *pipe
+	quiet.log = &logger{}
+
+	plans := make([][]page, len(images))
+	for i, img := range images {
+		plans[i], _ = pipe.plan(img)
+	}
+	if err := checkNames(images, plans); err != nil {
+		return nil, err
+	}
+
+	var result []chapter.ImageFile
+	for i, img := range images {
+		for _, pg := range plans[i] {
+			if pg.unchanged() {
+				result = append(result, img)
+				continue
+			}
+
+			name := pg.name
+			if pg.format == FormatAuto {
+				_, resolved, err := pipe.renderFile(img, pg)
+				if err != nil {
+					return nil, err
+				}
+				name = renamed(pg.name, resolved)
+			}
+
+			result = append(result, chapter.ImageFile{
+				Path:   img.Path,
+				Name:   name,
+				Entry:  img.Entry,
+				Source: &transcoder{file: img, page: pg, pipe: &quiet},
+			})
+		}
+	}
+	return result, nil
+}
+
+// transcoder decodes an image file, applies the pipeline stages and
+// encodes it into the writer in the target format.
+type transcoder struct {
+	file chapter.ImageFile
+	page page
+	pipe *pipeline
+}
+
+// Encode implements chapter.Source.
+func (t *transcoder) Encode(w io.Writer) error {
+	img, format, err := t.pipe.renderFile(t.file, t.page)
+	if err != nil {
+		return err
+	}
+
+	return t.pipe.policy.encode(w, img, format)
+}
+
+// Config returns the dimensions of the encoded page, so that archive
+// metadata can describe it before it is written.
+func (t *transcoder) Config() (image.Config, error) {
+	return t.pipe.config(t.file, t.page)
+}
@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+
+	"manga2cbz/internal/chapter"
+)
+
+func TestStreamImages(t *testing.T) {
+	tempDir := t.TempDir()
+
+	webpPath := filepath.Join(tempDir, "page1.webp")
+	createTestFile(t, webpPath, minimalWebP)
+	pngPath := filepath.Join(tempDir, "page2.png")
+	createTestPNG(t, pngPath)
+
+	images := []chapter.ImageFile{
+		{Path: webpPath, Name: "page1.webp"},
+		{Path: pngPath, Name: "page2.png"},
+	}
+
+	result, err := StreamImages(images, Options{})
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("result length = %d, want 2", len(result))
+	}
+
+	// WebP page is renamed and transcoded on demand from the original file
+	if result[0].Name != "page1.png" {
+		t.Errorf("result[0].Name = %q, want %q", result[0].Name, "page1.png")
+	}
+	if result[0].Path != webpPath {
+		t.Errorf("result[0].Path = %q, want original %q", result[0].Path, webpPath)
+	}
+	if result[0].Source == nil {
+		t.Fatal("result[0].Source should be set")
+	}
+
+	var buf bytes.Buffer
+	if err := result[0].Source.Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("encoded page is not a valid PNG: %v", err)
+	}
+
+	// Other pages pass through untouched
+	if result[1] != images[1] {
+		t.Errorf("result[1] = %+v, want %+v", result[1], images[1])
+	}
+}
+
+func TestStreamImages_InvalidWebP(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "bad.webp")
+	createTestFile(t, path, []byte("not valid webp data"))
+
+	result, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "bad.webp"}}, Options{})
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := result[0].Source.Encode(&buf); err == nil {
+		t.Error("Encode() should fail for invalid WebP")
+	}
+}
+
+func TestStreamImages_AutoDecidesNameUpfront(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "page.bmp")
+	writeImage(t, path, func(f *os.File, img image.Image) error {
+		return bmp.Encode(f, photoImage(32, 32))
+	})
+
+	var log bytes.Buffer
+	opts := Options{
+		Policy:  Policy{Targets: map[string]Format{"bmp": FormatAuto}},
+		Verbose: &log,
+	}
+
+	result, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "page.bmp"}}, opts)
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+	if result[0].Name != "page.jpg" {
+		t.Errorf("Name = %q, want %q", result[0].Name, "page.jpg")
+	}
+	if !strings.Contains(log.String(), "page.bmp -> page.jpg") {
+		t.Errorf("verbose log missing decision: %q", log.String())
+	}
+
+	var buf bytes.Buffer
+	if err := result[0].Source.Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := jpeg.Decode(&buf); err != nil {
+		t.Errorf("encoded page is not a valid JPEG: %v", err)
+	}
+}
+
+func TestStreamImages_AutoInvalidImage(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "bad.webp")
+	createTestFile(t, path, []byte("not valid webp data"))
+
+	opts := Options{Policy: Policy{Targets: map[string]Format{"webp": FormatAuto}}}
+	if _, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "bad.webp"}}, opts); err == nil {
+		t.Error("StreamImages() should fail when an auto page cannot be decoded")
+	}
+}
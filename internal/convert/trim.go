@@ -0,0 +1,133 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+)
+
+// Defaults for the zero-valued fields of Trim.
+const (
+	DefaultTrimTolerance   = 24
+	DefaultTrimNoise       = 0.005
+	DefaultTrimMaxFraction = 0.25
+)
+
+// Trim configures cropping of near-uniform margins, such as scanner
+// borders, from the edges of pages. Zero fields use the defaults above.
+type Trim struct {
+	Enabled     bool
+	Tolerance   int     // Largest gray level difference (0-255) from the margin color
+	Noise       float64 // Fraction of pixels in a margin row or column that may differ
+	MaxFraction float64 // Largest fraction of the width or height removed
+}
+
+// withDefaults returns t with zero fields set to their defaults.
+func (t Trim) withDefaults() Trim {
+	if t.Tolerance <= 0 {
+		t.Tolerance = DefaultTrimTolerance
+	}
+	if t.Noise <= 0 {
+		t.Noise = DefaultTrimNoise
+	}
+	if t.MaxFraction <= 0 {
+		t.MaxFraction = DefaultTrimMaxFraction
+	}
+	return t
+}
+
+// bounds returns the region of img left after removing its margins.
+// Returns img.Bounds() when there is nothing to trim, or when the whole
+// page is uniform (a blank page is not cropped to nothing).
+func (t Trim) bounds(img image.Image) image.Rectangle {
+	t = t.withDefaults()
+	b := img.Bounds()
+	if b.Empty() {
+		return b
+	}
+	gray := grayLevels(img)
+
+	row := func(y int) func(x int) uint8 { return func(x int) uint8 { return gray(x, y) } }
+	col := func(x int) func(y int) uint8 { return func(y int) uint8 { return gray(x, y) } }
+
+	// Rows first, then columns within the remaining rows, so that a
+	// top border of another color doesn't hide the side margins
+	top := t.margin(b.Min.Y, b.Max.Y, 1, b.Min.X, b.Max.X, row)
+	if top == b.Max.Y {
+		return b
+	}
+	bottom := t.margin(b.Max.Y-1, top-1, -1, b.Min.X, b.Max.X, row) + 1
+	left := t.margin(b.Min.X, b.Max.X, 1, top, bottom, col)
+	right := t.margin(b.Max.X-1, left-1, -1, top, bottom, col) + 1
+
+	left, right = t.clamp(b.Min.X, b.Max.X, left, right)
+	top, bottom = t.clamp(b.Min.Y, b.Max.Y, top, bottom)
+	return image.Rect(left, top, right, bottom)
+}
+
+// margin scans lines from start towards end (exclusive) in steps of dir
+// and returns the first line that is not margin. Each line spans [from,
+// to) and is read through line; the margin color is the median of the
+// first line.
+func (t Trim) margin(start, end, dir, from, to int, line func(int) func(int) uint8) int {
+	ref := median(from, to, line(start))
+	allowed := int(float64(to-from) * t.Noise)
+
+	i := start
+	for ; i != end; i += dir {
+		at := line(i)
+		outliers := 0
+		for j := from; j < to && outliers <= allowed; j++ {
+			if int(absDiff(uint32(at(j)), uint32(ref))) > t.Tolerance {
+				outliers++
+			}
+		}
+		if outliers > allowed {
+			break
+		}
+	}
+	return i
+}
+
+// clamp limits the margins of the span [start, end) trimmed to [lo, hi)
+// to MaxFraction of its size, shrinking both margins proportionally.
+func (t Trim) clamp(start, end, lo, hi int) (int, int) {
+	size := end - start
+	before, after := lo-start, end-hi
+	limit := int(float64(size) * t.MaxFraction)
+	if before+after <= limit {
+		return lo, hi
+	}
+	before = before * limit / (before + after)
+	after = limit - before
+	return start + before, end - after
+}
+
+// median returns the median gray level of the pixels at [from, to).
+func median(from, to int, at func(int) uint8) uint8 {
+	var histogram [256]int
+	for i := from; i < to; i++ {
+		histogram[at(i)]++
+	}
+	half := (to - from) / 2
+	for level, count := range histogram {
+		if half < count {
+			return uint8(level)
+		}
+		half -= count
+	}
+	return 0
+}
+
+// grayLevels returns a function reading the gray level of img at (x, y),
+// with fast paths for decoded JPEG and grayscale images.
+func grayLevels(img image.Image) func(x, y int) uint8 {
+	switch m := img.(type) {
+	case *image.Gray:
+		return func(x, y int) uint8 { return m.Pix[m.PixOffset(x, y)] }
+	case *image.YCbCr:
+		return func(x, y int) uint8 { return m.Y[m.YOffset(x, y)] }
+	}
+	return func(x, y int) uint8 {
+		return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+	}
+}
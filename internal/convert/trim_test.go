@@ -0,0 +1,139 @@
+package convert
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+// borderedImage returns a w x h gray image filled with border, with a
+// textured content area at content.
+func borderedImage(w, h int, border uint8, content image.Rectangle) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			level := border
+			if (image.Point{x, y}).In(content) {
+				level = uint8((x*7 + y*13) % 256)
+			}
+			img.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+	return img
+}
+
+func TestTrimBounds(t *testing.T) {
+	content := image.Rect(10, 10, 90, 140)
+
+	specked := borderedImage(100, 150, 255, content)
+	specked.SetGray(50, 5, color.Gray{Y: 0})
+
+	uneven := borderedImage(100, 150, 255, content)
+	for x := 0; x < 100; x++ {
+		uneven.SetGray(x, 0, color.Gray{Y: 0}) // Black scanner edge above white margin
+	}
+
+	tests := []struct {
+		name string
+		trim Trim
+		img  image.Image
+		want image.Rectangle
+	}{
+		{"white margins", Trim{Enabled: true}, borderedImage(100, 150, 255, content), content},
+		{"black margins", Trim{Enabled: true}, borderedImage(100, 150, 0, content), content},
+		{"near-white margins", Trim{Enabled: true}, borderedImage(100, 150, 240, content), content},
+		{"speck within noise", Trim{Enabled: true, Noise: 0.05}, specked, content},
+		{"speck beyond noise", Trim{Enabled: true, Noise: 0.001}, specked, image.Rect(10, 5, 90, 140)},
+		{"border only on top", Trim{Enabled: true}, uneven, image.Rect(10, 1, 90, 140)},
+		{"no margins", Trim{Enabled: true}, borderedImage(100, 150, 255, image.Rect(0, 0, 100, 150)), image.Rect(0, 0, 100, 150)},
+		{"blank page", Trim{Enabled: true}, borderedImage(100, 150, 255, image.Rectangle{}), image.Rect(0, 0, 100, 150)},
+		{"max fraction", Trim{Enabled: true, MaxFraction: 0.1}, borderedImage(100, 150, 255, content), image.Rect(5, 7, 95, 142)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.trim.bounds(tt.img); got != tt.want {
+				t.Errorf("bounds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertImages_TrimsOnlyBorderedPages(t *testing.T) {
+	tempDir := t.TempDir()
+
+	bordered := filepath.Join(tempDir, "01.png")
+	writePNG(t, bordered, borderedImage(100, 150, 255, image.Rect(10, 10, 90, 140)))
+	clean := filepath.Join(tempDir, "02.png")
+	writePNG(t, clean, borderedImage(100, 150, 255, image.Rect(0, 0, 100, 150)))
+
+	images := []chapter.ImageFile{
+		{Path: bordered, Name: "01.png"},
+		{Path: clean, Name: "02.png"},
+	}
+
+	var log bytes.Buffer
+	result, cleanup, err := ConvertImages(images, Options{Trim: Trim{Enabled: true}, Verbose: &log})
+	if err != nil {
+		t.Fatalf("ConvertImages returned error: %v", err)
+	}
+	defer cleanup()
+
+	if len(result) != 2 {
+		t.Fatalf("result length = %d, want 2", len(result))
+	}
+	if result[0].Name != "01.png" || result[0].Path == bordered {
+		t.Errorf("result[0] = %+v, want a trimmed copy named 01.png", result[0])
+	}
+	cfg, err := decodeImageConfig(result[0])
+	if err != nil {
+		t.Fatalf("failed to read trimmed page: %v", err)
+	}
+	if cfg.Width != 80 || cfg.Height != 130 {
+		t.Errorf("trimmed page is %dx%d, want 80x130", cfg.Width, cfg.Height)
+	}
+
+	// Clean pages are not re-encoded
+	if result[1] != images[1] {
+		t.Errorf("result[1] = %+v, want %+v", result[1], images[1])
+	}
+	if !strings.Contains(log.String(), "Trim: 01.png 100x150 -> 80x130") {
+		t.Errorf("verbose log missing trim:\n%s", log.String())
+	}
+}
+
+func TestStreamImages_TrimBeforeSplit(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "01.png")
+
+	// A 200x100 content spread with a wide border reads as portrait
+	writePNG(t, path, borderedImage(240, 300, 255, image.Rect(20, 100, 220, 200)))
+
+	opts := Options{Trim: Trim{Enabled: true, MaxFraction: 0.9}, Split: Split{Enabled: true}}
+	result, err := StreamImages([]chapter.ImageFile{{Path: path, Name: "01.png"}}, opts)
+	if err != nil {
+		t.Fatalf("StreamImages() error = %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("result length = %d, want 2 halves of the trimmed spread", len(result))
+	}
+
+	for i, img := range result {
+		var buf bytes.Buffer
+		if err := img.Source.Encode(&buf); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		decoded, _, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("encoded page is invalid: %v", err)
+		}
+		if decoded.Bounds().Dx() != 100 || decoded.Bounds().Dy() != 100 {
+			t.Errorf("result[%d] encoded as %v, want 100x100", i, decoded.Bounds())
+		}
+	}
+}
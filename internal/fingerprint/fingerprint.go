@@ -0,0 +1,123 @@
+// Package fingerprint identifies the inputs of a chapter archive, so that
+// unchanged chapters can be skipped when rebuilding.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"manga2cbz/internal/cbz"
+	"manga2cbz/internal/chapter"
+)
+
+// commentPrefix marks a fingerprint stored as a ZIP archive comment.
+const commentPrefix = "manga2cbz-fingerprint "
+
+// version is hashed first, so that changing what goes into a fingerprint
+// invalidates those of older archives.
+const version = "1"
+
+// Mode selects how page files are identified.
+type Mode int
+
+const (
+	// ModeStat uses each file's size and modification time. It is cheap,
+	// but touching a file without changing it counts as a change.
+	ModeStat Mode = iota
+
+	// ModeContent hashes each file's content.
+	ModeContent
+)
+
+// Fingerprint is a digest of a chapter's page files and the settings used
+// to convert them.
+type Fingerprint string
+
+// Of computes the fingerprint of images, in order, combined with settings,
+// a canonical description of every option that affects the archive's
+// content (for example convert.Options.Fingerprint, plus any archive
+// options). Settings must not include options that leave the content
+// alone, such as verbosity or worker counts, or every change to them
+// would rebuild all chapters.
+// Images must be the chapter's source files, before conversion.
+func Of(images []chapter.ImageFile, mode Mode, settings string) (Fingerprint, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%s\x00%d\x00%s\x00", version, mode, settings)
+
+	for _, img := range images {
+		fmt.Fprintf(h, "%s\x00", img.Name)
+
+		switch mode {
+		case ModeContent:
+			sum, err := hashFile(img)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s\n", sum)
+		default:
+			info, err := img.Stat()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%d\x00%d\n", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return Fingerprint("sha256:" + hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// Comment returns the ZIP archive comment that stores f.
+func (f Fingerprint) Comment() string {
+	return commentPrefix + string(f)
+}
+
+// Read returns the fingerprint stored in the comment of the archive at
+// path, or "" if it has none.
+func Read(path string) (Fingerprint, error) {
+	comment, err := cbz.ReadComment(path)
+	if err != nil {
+		return "", err
+	}
+
+	stored, ok := strings.CutPrefix(comment, commentPrefix)
+	if !ok {
+		return "", nil
+	}
+	return Fingerprint(stored), nil
+}
+
+// Changed reports whether the archive at path must be rebuilt for inputs
+// with fingerprint f: it is missing, unreadable, has no fingerprint, or
+// was built from different inputs. An archive split into parts (see
+// cbz.Parts) is unchanged only if every part carries f and the unsplit
+// archive is absent.
+func Changed(path string, f Fingerprint) bool {
+	paths := cbz.Parts(path)
+	if len(paths) == 0 || (len(paths) > 1 && paths[0] == path) {
+		return true
+	}
+	for _, p := range paths {
+		if stored, err := Read(p); err != nil || stored != f {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFile returns the hex SHA-256 digest of the image's content.
+func hashFile(img chapter.ImageFile) (string, error) {
+	file, err := img.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
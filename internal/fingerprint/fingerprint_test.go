@@ -0,0 +1,192 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"manga2cbz/internal/cbz"
+	"manga2cbz/internal/chapter"
+)
+
+// createPages writes page files with the given contents and returns them
+// as chapter images.
+func createPages(t *testing.T, dir string, contents ...string) []chapter.ImageFile {
+	t.Helper()
+	images := make([]chapter.ImageFile, len(contents))
+	for i, content := range contents {
+		name := string(rune('1'+i)) + ".jpg"
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create page: %v", err)
+		}
+		images[i] = chapter.ImageFile{Path: path, Name: name}
+	}
+	return images
+}
+
+// mustOf computes a fingerprint or fails the test.
+func mustOf(t *testing.T, images []chapter.ImageFile, mode Mode, settings string) Fingerprint {
+	t.Helper()
+	f, err := Of(images, mode, settings)
+	if err != nil {
+		t.Fatalf("Of() error = %v", err)
+	}
+	return f
+}
+
+func TestOf_DetectsChanges(t *testing.T) {
+	for _, mode := range []Mode{ModeStat, ModeContent} {
+		dir := t.TempDir()
+		images := createPages(t, dir, "page one", "page two")
+		base := mustOf(t, images, mode, "jpeg")
+
+		if again := mustOf(t, images, mode, "jpeg"); again != base {
+			t.Errorf("mode %d: fingerprint not stable: %s != %s", mode, again, base)
+		}
+		if !strings.HasPrefix(string(base), "sha256:") {
+			t.Errorf("mode %d: fingerprint = %q, want sha256: prefix", mode, base)
+		}
+
+		// Settings are part of the fingerprint
+		if mustOf(t, images, mode, "png") == base {
+			t.Errorf("mode %d: settings change not detected", mode)
+		}
+
+		// So are added pages and renamed pages
+		added := append(images, createPages(t, t.TempDir(), "a", "b", "page three")[2])
+		if mustOf(t, added, mode, "jpeg") == base {
+			t.Errorf("mode %d: added page not detected", mode)
+		}
+		renamed := append([]chapter.ImageFile{}, images...)
+		renamed[1].Name = "9.jpg"
+		if mustOf(t, renamed, mode, "jpeg") == base {
+			t.Errorf("mode %d: renamed page not detected", mode)
+		}
+
+		// And edited content, even at the same size
+		if err := os.WriteFile(images[0].Path, []byte("page ONE"), 0644); err != nil {
+			t.Fatalf("failed to edit page: %v", err)
+		}
+		future := time.Now().Add(time.Hour)
+		if err := os.Chtimes(images[0].Path, future, future); err != nil {
+			t.Fatalf("failed to touch page: %v", err)
+		}
+		if mustOf(t, images, mode, "jpeg") == base {
+			t.Errorf("mode %d: edited page not detected", mode)
+		}
+	}
+}
+
+func TestOf_TouchOnlyChangesStatMode(t *testing.T) {
+	images := createPages(t, t.TempDir(), "page one")
+	stat := mustOf(t, images, ModeStat, "")
+	content := mustOf(t, images, ModeContent, "")
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(images[0].Path, future, future); err != nil {
+		t.Fatalf("failed to touch page: %v", err)
+	}
+
+	if mustOf(t, images, ModeStat, "") == stat {
+		t.Error("ModeStat should detect a new modification time")
+	}
+	if mustOf(t, images, ModeContent, "") != content {
+		t.Error("ModeContent should ignore a new modification time")
+	}
+}
+
+func TestOf_MissingFile(t *testing.T) {
+	images := []chapter.ImageFile{{Path: filepath.Join(t.TempDir(), "missing.jpg"), Name: "missing.jpg"}}
+	for _, mode := range []Mode{ModeStat, ModeContent} {
+		if _, err := Of(images, mode, ""); err == nil {
+			t.Errorf("mode %d: expected error for missing file", mode)
+		}
+	}
+}
+
+func TestChanged_RoundTripsThroughArchive(t *testing.T) {
+	dir := t.TempDir()
+	images := createPages(t, dir, "page one", "page two")
+	f := mustOf(t, images, ModeStat, "")
+	archive := filepath.Join(dir, "chapter.cbz")
+
+	if !Changed(archive, f) {
+		t.Error("missing archive should count as changed")
+	}
+
+	// Archives from before fingerprinting have no comment
+	if err := cbz.Create(archive, images, cbz.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if stored, err := Read(archive); err != nil || stored != "" {
+		t.Errorf("Read() = %q, %v; want empty fingerprint", stored, err)
+	}
+	if !Changed(archive, f) {
+		t.Error("archive without fingerprint should count as changed")
+	}
+
+	if err := cbz.Create(archive, images, cbz.CreateOptions{Force: true, Comment: f.Comment()}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if stored, err := Read(archive); err != nil || stored != f {
+		t.Errorf("Read() = %q, %v; want %q", stored, err, f)
+	}
+	if Changed(archive, f) {
+		t.Error("archive built from the same inputs should be unchanged")
+	}
+	if !Changed(archive, mustOf(t, images, ModeStat, "resize")) {
+		t.Error("archive built with other settings should count as changed")
+	}
+
+	// Another chapter's archive that is only named like a part
+	other := cbz.PartPath(archive, 1)
+	if err := cbz.Create(other, images, cbz.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if Changed(archive, f) {
+		t.Error("archive named like a part should not count as one")
+	}
+}
+
+func TestChanged_SplitArchive(t *testing.T) {
+	dir := t.TempDir()
+	images := createPages(t, dir, "page one", "page two", "page three")
+	f := mustOf(t, images, ModeStat, "max-pages=2")
+	archive := filepath.Join(dir, "chapter.cbz")
+
+	paths, err := cbz.CreateParts(archive, images, cbz.CreateOptions{MaxPages: 2, Comment: f.Comment()})
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("CreateParts() = %v, %v; want 2 parts", paths, err)
+	}
+	if Changed(archive, f) {
+		t.Error("split archive built from the same inputs should be unchanged")
+	}
+	if !Changed(archive, mustOf(t, images, ModeStat, "max-pages=3")) {
+		t.Error("split archive built with other settings should count as changed")
+	}
+
+	// A part with another fingerprint, or a leftover unsplit archive,
+	// means the set is inconsistent
+	if err := cbz.Create(paths[1], images[2:], cbz.CreateOptions{Force: true}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !Changed(archive, f) {
+		t.Error("part without fingerprint should count as changed")
+	}
+	if _, err := cbz.CreateParts(archive, images, cbz.CreateOptions{Force: true, MaxPages: 2, Comment: f.Comment()}); err != nil {
+		t.Fatalf("CreateParts() error = %v", err)
+	}
+	leftover := filepath.Join(t.TempDir(), "chapter.cbz")
+	if err := cbz.Create(leftover, images, cbz.CreateOptions{Comment: f.Comment()}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := os.Rename(leftover, archive); err != nil {
+		t.Fatal(err)
+	}
+	if !Changed(archive, f) {
+		t.Error("unsplit archive next to parts should count as changed")
+	}
+}
@@ -0,0 +1,387 @@
+// Package naming builds output archive paths from chapter metadata.
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"manga2cbz/internal/chapter"
+)
+
+// DefaultTemplate reproduces the historical naming: the chapter's relative
+// path with separators replaced by underscores ("Volume01_Chapter001").
+const DefaultTemplate = "{flatpath}"
+
+// Template is a parsed output path template.
+//
+// Placeholders are written as {name} or {name:0N}, where 0N zero-pads the
+// integer part of a number to N digits ("{chapter:03}" turns "21.5" into
+// "021.5"). Supported names:
+//
+//	{series}    parsed series name
+//	{volume}    parsed volume number
+//	{chapter}   parsed chapter number
+//	{title}     parsed chapter title
+//	{group}     parsed scanlation group
+//	{name}      chapter directory name (last path component)
+//	{path}      relative chapter path, keeping its subdirectories
+//	{parent}    relative path of the chapter's parent directory
+//	{flatpath}  relative path joined with underscores
+//	{pathN}     N-th component of the relative path, starting at 1
+//
+// Text between < and > is an optional section, dropped entirely when any
+// placeholder inside it is empty: "{series}< v{volume:02}> c{chapter:03}".
+// A "/" in the template creates subdirectories.
+type Template struct {
+	source   string
+	segments []segment
+}
+
+// segment is a literal, a placeholder or an optional section.
+type segment struct {
+	literal  string
+	field    string    // Placeholder name; empty for literals and sections
+	width    int       // Zero-padding width for placeholders
+	optional []segment // Children of an optional section
+}
+
+// Parse parses a template string.
+func Parse(tmpl string) (*Template, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil, errors.New("empty name template")
+	}
+
+	segments, rest, err := parseSegments(tmpl, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template %q: %w", tmpl, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid name template %q: unmatched '>'", tmpl)
+	}
+
+	return &Template{source: tmpl, segments: segments}, nil
+}
+
+// String returns the template source.
+func (t *Template) String() string {
+	return t.source
+}
+
+// parseSegments parses s until the end or, inside a section, a closing '>'.
+// Returns the parsed segments and the unparsed remainder starting at '>'.
+func parseSegments(s string, inSection bool) ([]segment, string, error) {
+	var segments []segment
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			segments = append(segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '{':
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return nil, "", errors.New("unclosed '{'")
+			}
+			placeholder, err := parsePlaceholder(s[1:end])
+			if err != nil {
+				return nil, "", err
+			}
+			flush()
+			segments = append(segments, placeholder)
+			s = s[end+1:]
+		case '}':
+			return nil, "", errors.New("unmatched '}'")
+		case '<':
+			if inSection {
+				return nil, "", errors.New("nested optional sections are not supported")
+			}
+			children, rest, err := parseSegments(s[1:], true)
+			if err != nil {
+				return nil, "", err
+			}
+			if rest == "" {
+				return nil, "", errors.New("unclosed '<'")
+			}
+			flush()
+			segments = append(segments, segment{optional: children})
+			s = rest[1:]
+		case '>':
+			flush()
+			return segments, s, nil
+		default:
+			literal.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+
+	flush()
+	return segments, "", nil
+}
+
+// parsePlaceholder parses the inside of {name} or {name:0N}.
+func parsePlaceholder(s string) (segment, error) {
+	name, format, hasFormat := strings.Cut(s, ":")
+	if !isKnownField(name) {
+		return segment{}, fmt.Errorf("unknown placeholder {%s}", s)
+	}
+
+	seg := segment{field: name}
+	if hasFormat {
+		width, err := strconv.Atoi(format)
+		if err != nil || width <= 0 || !strings.HasPrefix(format, "0") {
+			return segment{}, fmt.Errorf("invalid padding %q in {%s}, expected e.g. :03", format, s)
+		}
+		seg.width = width
+	}
+	return seg, nil
+}
+
+// isKnownField reports whether name is a supported placeholder.
+func isKnownField(name string) bool {
+	switch name {
+	case "series", "volume", "chapter", "title", "group", "name", "path", "parent", "flatpath":
+		return true
+	}
+	if n, ok := strings.CutPrefix(name, "path"); ok {
+		index, err := strconv.Atoi(n)
+		return err == nil && index > 0
+	}
+	return false
+}
+
+// Execute renders the template for ch.
+// The result is a relative path using the OS path separator, without extension.
+// Characters that are invalid in file names are replaced with "_".
+func (t *Template) Execute(ch chapter.Chapter) (string, error) {
+	rendered, _ := render(t.segments, ch)
+
+	var components []string
+	for _, component := range strings.Split(rendered, "/") {
+		component = sanitizeComponent(component)
+		if component == "" {
+			continue
+		}
+		if component == "." || component == ".." {
+			return "", fmt.Errorf("name template %q produced invalid path %q for %s", t.source, rendered, ch.Name)
+		}
+		components = append(components, component)
+	}
+
+	if len(components) == 0 {
+		return "", fmt.Errorf("name template %q produced an empty name for %s", t.source, ch.Name)
+	}
+
+	return filepath.Join(components...), nil
+}
+
+// render renders segments, using "/" as the directory separator.
+// Returns false if any placeholder rendered to an empty value.
+func render(segments []segment, ch chapter.Chapter) (string, bool) {
+	var sb strings.Builder
+	complete := true
+
+	for _, seg := range segments {
+		switch {
+		case seg.optional != nil:
+			if section, ok := render(seg.optional, ch); ok {
+				sb.WriteString(section)
+			}
+		case seg.field != "":
+			value := fieldValue(seg.field, ch)
+			if value == "" {
+				complete = false
+			}
+			sb.WriteString(pad(value, seg.width))
+		default:
+			sb.WriteString(seg.literal)
+		}
+	}
+
+	return sb.String(), complete
+}
+
+// fieldValue returns the value of a placeholder for ch.
+// Only {path} and {parent} may contain "/"; other values have
+// separators replaced so they cannot create directories.
+func fieldValue(field string, ch chapter.Chapter) string {
+	components := strings.Split(filepath.ToSlash(ch.Name), "/")
+
+	switch field {
+	case "series":
+		return flatten(ch.Series)
+	case "volume":
+		return flatten(ch.Volume)
+	case "chapter":
+		return flatten(ch.Number)
+	case "title":
+		return flatten(ch.Title)
+	case "group":
+		return flatten(ch.Group)
+	case "name":
+		return components[len(components)-1]
+	case "path":
+		return strings.Join(components, "/")
+	case "parent":
+		return strings.Join(components[:len(components)-1], "/")
+	case "flatpath":
+		return strings.Join(components, "_")
+	}
+
+	// {pathN}
+	index, _ := strconv.Atoi(strings.TrimPrefix(field, "path"))
+	if index > len(components) {
+		return ""
+	}
+	return components[index-1]
+}
+
+// flatten replaces path separators so a value stays within one component.
+func flatten(s string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(s)
+}
+
+// pad zero-pads the leading integer part of s to width digits.
+// Values that do not start with a digit are returned unchanged.
+func pad(s string, width int) string {
+	digits := 0
+	for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits >= width {
+		return s
+	}
+	return strings.Repeat("0", width-digits) + s
+}
+
+// sanitizeComponent replaces characters that are invalid in file names on
+// common platforms and trims spaces and trailing dots (invalid on Windows).
+func sanitizeComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if s != "." && s != ".." {
+		s = strings.TrimRight(s, ". ")
+	}
+	return s
+}
+
+// Collision describes several chapters that would be written to one path.
+type Collision struct {
+	Path     string
+	Chapters []string
+}
+
+// CollisionError reports output paths shared by more than one chapter.
+type CollisionError struct {
+	Collisions []Collision
+}
+
+func (e *CollisionError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d output name collision(s):", len(e.Collisions))
+	for _, c := range e.Collisions {
+		fmt.Fprintf(&sb, "\n  %s <- %s", c.Path, strings.Join(c.Chapters, ", "))
+	}
+	return sb.String()
+}
+
+// Plan computes the output path of every chapter under outputDir, adding
+// ext (e.g. ".cbz") unless the rendered name already ends with it.
+// Returns a *CollisionError if two chapters map to the same path, and an
+// error if an output path is a chapter's source path or contains it.
+// Paths are compared case-insensitively, since Windows and macOS file
+// systems would otherwise silently overwrite one archive with another.
+// Nothing is written to disk.
+func Plan(chapters []chapter.Chapter, tmpl *Template, outputDir, ext string) ([]string, error) {
+	paths := make([]string, len(chapters))
+	owners := make(map[string][]int)
+	var keys []string
+
+	for i, ch := range chapters {
+		rel, err := tmpl.Execute(ch)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(filepath.Ext(rel), ext) {
+			rel += ext
+		}
+		paths[i] = filepath.Join(outputDir, rel)
+
+		key := strings.ToLower(paths[i])
+		if _, seen := owners[key]; !seen {
+			keys = append(keys, key)
+		}
+		owners[key] = append(owners[key], i)
+	}
+
+	var collisions []Collision
+	sort.Strings(keys)
+	for _, key := range keys {
+		indexes := owners[key]
+		if len(indexes) < 2 {
+			continue
+		}
+		c := Collision{Path: paths[indexes[0]]}
+		for _, i := range indexes {
+			c.Chapters = append(c.Chapters, chapters[i].Name)
+		}
+		collisions = append(collisions, c)
+	}
+
+	if len(collisions) > 0 {
+		return nil, &CollisionError{Collisions: collisions}
+	}
+
+	// Replacing an output must never remove a chapter's source, as when
+	// folder output lands on the input folders
+	for _, path := range paths {
+		for _, ch := range chapters {
+			if ch.Path != "" && ContainsPath(path, ch.Path) {
+				return nil, fmt.Errorf("output %s would replace the source of chapter %s", path, ch.Name)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// ContainsPath reports whether path is parent or lies inside it, compared
+// case-insensitively as output paths are.
+func ContainsPath(parent, path string) bool {
+	parent, errParent := filepath.Abs(parent)
+	path, errPath := filepath.Abs(path)
+	if errParent != nil || errPath != nil {
+		return false
+	}
+	rel, err := filepath.Rel(strings.ToLower(parent), strings.ToLower(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CreateDirs creates the parent directories of the planned paths.
+func CreateDirs(paths []string) error {
+	created := make(map[string]bool)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if created[dir] {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		created[dir] = true
+	}
+	return nil
+}
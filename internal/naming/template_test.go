@@ -0,0 +1,284 @@
+package naming
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"manga2cbz/internal/chapter"
+)
+
+var testChapter = chapter.Chapter{
+	Name:   filepath.Join("Volume03", "Ch.021.5 - The Storm"),
+	Series: "One Piece",
+	Volume: "3",
+	Number: "21.5",
+	Title:  "The Storm",
+	Group:  "Scans",
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"default", DefaultTemplate, "Volume03_Ch.021.5 - The Storm"},
+		{"padding", "{series} - v{volume:02} c{chapter:03}", "One Piece - v03 c021.5"},
+		{"no padding needed", "c{chapter:01}", "c21.5"},
+		{"title and group", "{series} - {title} [{group}]", "One Piece - The Storm [Scans]"},
+		{"subdirectory", "{series}/v{volume:02}/c{chapter:03}", filepath.Join("One Piece", "v03", "c021.5")},
+		{"path kept", "{path}", filepath.Join("Volume03", "Ch.021.5 - The Storm")},
+		{"parent and name", "{parent} - {name}", "Volume03 - Ch.021.5 - The Storm"},
+		{"path components", "{path1}/{path2}", filepath.Join("Volume03", "Ch.021.5 - The Storm")},
+		{"missing component", "{name}{path3}", "Ch.021.5 - The Storm"},
+		{"optional kept", "{series}< v{volume:02}> c{chapter:03}", "One Piece v03 c021.5"},
+		{"extension kept", "{series} c{chapter:03}.cbz", "One Piece c021.5.cbz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.template)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.template, err)
+			}
+			got, err := tmpl.Execute(testChapter)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_OptionalSectionDropped(t *testing.T) {
+	tmpl, err := Parse("{series}< v{volume:02}> c{chapter:03}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := tmpl.Execute(chapter.Chapter{Name: "Ch.5", Series: "Solo", Number: "5"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got != "Solo c005" {
+		t.Errorf("Execute() = %q, want %q", got, "Solo c005")
+	}
+}
+
+func TestExecute_Sanitizes(t *testing.T) {
+	tmpl, err := Parse("{series} - {title}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := tmpl.Execute(chapter.Chapter{Name: "x", Series: "Re:Zero", Title: "Why/How?"})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got != "Re_Zero - Why_How_" {
+		t.Errorf("Execute() = %q, want %q", got, "Re_Zero - Why_How_")
+	}
+}
+
+func TestExecute_RejectsTraversal(t *testing.T) {
+	tmpl, err := Parse("../{name}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := tmpl.Execute(testChapter); err == nil {
+		t.Error("Execute() should reject paths that leave the output directory")
+	}
+}
+
+func TestExecute_EmptyResult(t *testing.T) {
+	tmpl, err := Parse("{volume}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := tmpl.Execute(chapter.Chapter{Name: "Extras"}); err == nil {
+		t.Error("Execute() should fail when the name renders empty")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		"{unknown}",
+		"{series",
+		"series}",
+		"{chapter:3}",
+		"{chapter:abc}",
+		"{path0}",
+		"<{series}",
+		"{series}>",
+		"<a<b>>",
+	}
+
+	for _, tmpl := range tests {
+		if _, err := Parse(tmpl); err == nil {
+			t.Errorf("Parse(%q) should return an error", tmpl)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tmpl, err := Parse("{series} - c{chapter:03}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	chapters := []chapter.Chapter{
+		{Name: "Ch.1", Series: "S", Number: "1"},
+		{Name: "Ch.2", Series: "S", Number: "2"},
+	}
+
+	paths, err := Plan(chapters, tmpl, "/out", ".cbz")
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	want := []string{filepath.Join("/out", "S - c001.cbz"), filepath.Join("/out", "S - c002.cbz")}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("paths[%d] = %q, want %q", i, paths[i], want[i])
+		}
+	}
+}
+
+func TestPlan_ExtensionNotDuplicated(t *testing.T) {
+	tmpl, err := Parse("{name}.CBZ")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	paths, err := Plan([]chapter.Chapter{{Name: "Ch.1"}}, tmpl, "/out", ".cbz")
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if paths[0] != filepath.Join("/out", "Ch.1.CBZ") {
+		t.Errorf("paths[0] = %q", paths[0])
+	}
+}
+
+func TestPlan_Collisions(t *testing.T) {
+	tmpl, err := Parse("{series} - c{chapter:03}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// Two chapters parse to the same number; case differences also collide
+	chapters := []chapter.Chapter{
+		{Name: "[A] Ch.1", Series: "S", Number: "1"},
+		{Name: "[B] Ch.1", Series: "s", Number: "1"},
+		{Name: "Ch.2", Series: "S", Number: "2"},
+	}
+
+	_, err = Plan(chapters, tmpl, "/out", ".cbz")
+	var collisionErr *CollisionError
+	if !errors.As(err, &collisionErr) {
+		t.Fatalf("Plan() error = %v, want *CollisionError", err)
+	}
+	if len(collisionErr.Collisions) != 1 {
+		t.Fatalf("expected 1 collision, got %d", len(collisionErr.Collisions))
+	}
+	c := collisionErr.Collisions[0]
+	if len(c.Chapters) != 2 || c.Chapters[0] != "[A] Ch.1" || c.Chapters[1] != "[B] Ch.1" {
+		t.Errorf("unexpected collision chapters: %v", c.Chapters)
+	}
+	if !strings.Contains(err.Error(), "[A] Ch.1, [B] Ch.1") {
+		t.Errorf("error message should list colliding chapters: %v", err)
+	}
+}
+
+func TestPlan_RefusesSourcePaths(t *testing.T) {
+	flat, err := Parse(DefaultTemplate)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	series, err := Parse("{series}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	input := filepath.Join(t.TempDir(), "Manga")
+
+	tests := []struct {
+		name      string
+		chapters  []chapter.Chapter
+		tmpl      *Template
+		outputDir string
+		ext       string
+		wantErr   bool
+	}{
+		{
+			// Folder output into the input directory
+			name:      "output is the source folder",
+			chapters:  []chapter.Chapter{{Name: "Ch 1", Path: filepath.Join(input, "Ch 1")}},
+			tmpl:      flat,
+			outputDir: input,
+			wantErr:   true,
+		},
+		{
+			name:      "output contains the source folder",
+			chapters:  []chapter.Chapter{{Name: filepath.Join("Berserk", "Ch 1"), Series: "Berserk", Path: filepath.Join(input, "Berserk", "Ch 1")}},
+			tmpl:      series,
+			outputDir: input,
+			wantErr:   true,
+		},
+		{
+			name:      "output is the source archive",
+			chapters:  []chapter.Chapter{{Name: "Ch 1", Path: filepath.Join(input, "Ch 1.cbz")}},
+			tmpl:      flat,
+			outputDir: input,
+			ext:       ".cbz",
+			wantErr:   true,
+		},
+		{
+			name:      "archive next to the source folder",
+			chapters:  []chapter.Chapter{{Name: "Ch 1", Path: filepath.Join(input, "Ch 1")}},
+			tmpl:      flat,
+			outputDir: input,
+			ext:       ".cbz",
+		},
+		{
+			name:      "folder output elsewhere",
+			chapters:  []chapter.Chapter{{Name: "Ch 1", Path: filepath.Join(input, "Ch 1")}},
+			tmpl:      flat,
+			outputDir: input + " out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Plan(tt.chapters, tt.tmpl, tt.outputDir, tt.ext)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateDirs(t *testing.T) {
+	root := t.TempDir()
+	paths := []string{
+		filepath.Join(root, "S", "v01", "c001.cbz"),
+		filepath.Join(root, "S", "v01", "c002.cbz"),
+		filepath.Join(root, "S", "v02", "c003.cbz"),
+	}
+
+	if err := CreateDirs(paths); err != nil {
+		t.Fatalf("CreateDirs() error = %v", err)
+	}
+
+	for _, p := range paths {
+		if info, err := os.Stat(filepath.Dir(p)); err != nil || !info.IsDir() {
+			t.Errorf("directory %s was not created", filepath.Dir(p))
+		}
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("CreateDirs() should not create %s", p)
+		}
+	}
+}
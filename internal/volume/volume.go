@@ -0,0 +1,178 @@
+// Package volume merges chapters into volume-sized groups, each written
+// as a single archive.
+package volume
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"manga2cbz/internal/cbz"
+	"manga2cbz/internal/chapter"
+)
+
+// Mode selects how chapters are grouped.
+type Mode int
+
+const (
+	// ByVolume groups chapters by parsed series and volume number.
+	// Chapters without a volume are left in groups of their own.
+	ByVolume Mode = iota
+
+	// ByParent groups chapters by parent directory (for recursive
+	// discovery, e.g. "Series/Vol 1/Chapter 1").
+	ByParent
+
+	// ByCount groups a fixed number of consecutive chapters.
+	ByCount
+)
+
+// ParseMode parses a grouping mode name: "volume", "parent" or
+// "count".
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "volume":
+		return ByVolume, nil
+	case "parent":
+		return ByParent, nil
+	case "count":
+		return ByCount, nil
+	}
+	return 0, fmt.Errorf("unknown grouping %q, expected volume, parent or count", s)
+}
+
+// Group is a set of consecutive chapters written as one archive.
+// Chapter describes the merged archive, so that it can be named with a
+// naming template like a single chapter; its Path is empty.
+type Group struct {
+	Chapter  chapter.Chapter
+	Chapters []chapter.Chapter
+}
+
+// GroupChapters groups chapters, which must already be in reading order
+// (as returned by chapter.Discover). Groups keep that order. size is the
+// number of chapters per group for ByCount and is ignored otherwise.
+func GroupChapters(chapters []chapter.Chapter, mode Mode, size int) ([]Group, error) {
+	if mode == ByCount && size < 1 {
+		return nil, fmt.Errorf("invalid group size %d", size)
+	}
+
+	var groups []Group
+	var key string
+	for i, ch := range chapters {
+		var k string
+		switch mode {
+		case ByVolume:
+			k = ch.Series + "\x00" + ch.Volume
+			if ch.Volume == "" {
+				// Unnumbered chapters stand alone
+				k = fmt.Sprintf("\x00%d", i)
+			}
+		case ByParent:
+			k = filepath.Dir(ch.Name)
+		case ByCount:
+			k = fmt.Sprint(i / size)
+		default:
+			return nil, fmt.Errorf("unknown grouping mode %d", mode)
+		}
+
+		if len(groups) == 0 || k != key {
+			groups = append(groups, Group{})
+			key = k
+		}
+		g := &groups[len(groups)-1]
+		g.Chapters = append(g.Chapters, ch)
+	}
+
+	for i := range groups {
+		groups[i].Chapter = merged(groups[i].Chapters, mode)
+	}
+	return groups, nil
+}
+
+// merged describes the archive for a group of chapters. Fields shared by
+// every chapter are kept; Number spans the first and last chapters.
+func merged(chapters []chapter.Chapter, mode Mode) chapter.Chapter {
+	first, last := chapters[0], chapters[len(chapters)-1]
+	if len(chapters) == 1 {
+		return first
+	}
+
+	m := chapter.Chapter{
+		Series: common(chapters, func(ch chapter.Chapter) string { return ch.Series }),
+		Volume: common(chapters, func(ch chapter.Chapter) string { return ch.Volume }),
+		Group:  common(chapters, func(ch chapter.Chapter) string { return ch.Group }),
+	}
+	if first.Number != "" && last.Number != "" {
+		m.Number = first.Number + "-" + last.Number
+	}
+
+	// Keep the group in the folder its chapters share
+	parent := filepath.Dir(first.Name)
+	if filepath.Dir(last.Name) != parent {
+		parent = "."
+	}
+
+	switch {
+	case mode == ByParent && parent != ".":
+		m.Name = parent
+	case m.Volume != "":
+		m.Name = filepath.Join(parent, strings.TrimSpace(m.Series+" Vol. "+m.Volume))
+	default:
+		m.Name = filepath.Join(parent, filepath.Base(first.Name)+" - "+filepath.Base(last.Name))
+	}
+	m.Title = filepath.Base(m.Name)
+	return m
+}
+
+// common returns the value of field shared by all chapters, or "".
+func common(chapters []chapter.Chapter, field func(chapter.Chapter) string) string {
+	v := field(chapters[0])
+	for _, ch := range chapters[1:] {
+		if field(ch) != v {
+			return ""
+		}
+	}
+	return v
+}
+
+// Pages merges the pages of the group's chapters into one list for the
+// archive. images[i] holds the pages of g.Chapters[i], in order.
+//
+// Each page name is prefixed with its zero-padded chapter position
+// ("01_", "02_", ...) so that chapter boundaries keep their order under
+// natural sort. The returned page list bookmarks the first page of each
+// chapter, for use as cbz.ComicInfo.Pages.
+func (g Group) Pages(images [][]chapter.ImageFile) ([]chapter.ImageFile, cbz.PageList) {
+	width := len(fmt.Sprint(len(g.Chapters)))
+
+	var pages []chapter.ImageFile
+	var bookmarks cbz.PageList
+	for i, chapterImages := range images {
+		if len(chapterImages) == 0 {
+			continue
+		}
+		if i < len(g.Chapters) {
+			bookmarks = append(bookmarks, cbz.PageInfo{
+				Image:    len(pages),
+				Bookmark: bookmark(g.Chapters[i]),
+			})
+		}
+		for _, img := range chapterImages {
+			img.Name = fmt.Sprintf("%0*d_%s", width, i+1, img.Name)
+			pages = append(pages, img)
+		}
+	}
+	return pages, bookmarks
+}
+
+// bookmark labels the first page of a chapter in a merged archive.
+func bookmark(ch chapter.Chapter) string {
+	switch {
+	case ch.Number != "" && ch.Title != "":
+		return "Chapter " + ch.Number + ": " + ch.Title
+	case ch.Number != "":
+		return "Chapter " + ch.Number
+	}
+	return filepath.Base(ch.Name)
+}
@@ -0,0 +1,162 @@
+package volume
+
+import (
+	"path/filepath"
+	"testing"
+
+	"manga2cbz/internal/cbz"
+	"manga2cbz/internal/chapter"
+	"manga2cbz/internal/sort"
+)
+
+// groupNames returns the merged chapter names and sizes of groups.
+func groupNames(groups []Group) ([]string, []int) {
+	names := make([]string, len(groups))
+	sizes := make([]int, len(groups))
+	for i, g := range groups {
+		names[i] = g.Chapter.Name
+		sizes[i] = len(g.Chapters)
+	}
+	return names, sizes
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Mode
+	}{
+		{"volume", ByVolume},
+		{"Parent", ByParent},
+		{" count ", ByCount},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMode(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseMode("series"); err == nil {
+		t.Error("ParseMode(\"series\") should fail")
+	}
+}
+
+func TestGroupChapters(t *testing.T) {
+	chapters := []chapter.Chapter{
+		{Name: "Berserk v01 c001", Series: "Berserk", Volume: "1", Number: "1"},
+		{Name: "Berserk v01 c002", Series: "Berserk", Volume: "1", Number: "2"},
+		{Name: "Berserk v02 c003", Series: "Berserk", Volume: "2", Number: "3"},
+		{Name: "Berserk c004", Series: "Berserk", Number: "4"},
+		{Name: "Berserk c005", Series: "Berserk", Number: "5"},
+	}
+
+	tests := []struct {
+		name      string
+		mode      Mode
+		size      int
+		wantNames []string
+		wantSizes []int
+	}{
+		{
+			name:      "by volume",
+			mode:      ByVolume,
+			wantNames: []string{"Berserk Vol. 1", "Berserk v02 c003", "Berserk c004", "Berserk c005"},
+			wantSizes: []int{2, 1, 1, 1},
+		},
+		{
+			name:      "by count",
+			mode:      ByCount,
+			size:      2,
+			wantNames: []string{"Berserk Vol. 1", "Berserk v02 c003 - Berserk c004", "Berserk c005"},
+			wantSizes: []int{2, 2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := GroupChapters(chapters, tt.mode, tt.size)
+			if err != nil {
+				t.Fatalf("GroupChapters() error = %v", err)
+			}
+			names, sizes := groupNames(groups)
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("groups = %v %v, want %v %v", names, sizes, tt.wantNames, tt.wantSizes)
+			}
+			for i := range names {
+				if names[i] != tt.wantNames[i] || sizes[i] != tt.wantSizes[i] {
+					t.Errorf("group %d = %q (%d chapters), want %q (%d)", i, names[i], sizes[i], tt.wantNames[i], tt.wantSizes[i])
+				}
+			}
+		})
+	}
+
+	if _, err := GroupChapters(chapters, ByCount, 0); err == nil {
+		t.Error("GroupChapters() with size 0 should fail")
+	}
+}
+
+func TestGroupChapters_ByParent(t *testing.T) {
+	chapters := []chapter.Chapter{
+		{Name: filepath.Join("Series", "Vol 1", "Ch 1"), Series: "Series", Volume: "1", Number: "1"},
+		{Name: filepath.Join("Series", "Vol 1", "Ch 2"), Series: "Series", Volume: "1", Number: "2"},
+		{Name: filepath.Join("Series", "Vol 2", "Ch 3"), Series: "Series", Volume: "2", Number: "3"},
+		{Name: filepath.Join("Series", "Vol 2", "Ch 4"), Series: "Series", Volume: "2", Number: "4"},
+	}
+
+	groups, err := GroupChapters(chapters, ByParent, 0)
+	if err != nil {
+		t.Fatalf("GroupChapters() error = %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+
+	got := groups[1].Chapter
+	if got.Name != filepath.Join("Series", "Vol 2") || got.Series != "Series" || got.Volume != "2" || got.Number != "3-4" {
+		t.Errorf("merged chapter = %+v", got)
+	}
+}
+
+func TestGroupPages(t *testing.T) {
+	g := Group{Chapters: make([]chapter.Chapter, 10)}
+	g.Chapters[0] = chapter.Chapter{Name: "c1", Number: "1", Title: "Dawn"}
+	g.Chapters[1] = chapter.Chapter{Name: "c2"}
+	g.Chapters[9] = chapter.Chapter{Name: "c10", Number: "10"}
+
+	images := make([][]chapter.ImageFile, 10)
+	images[0] = []chapter.ImageFile{{Name: "9.jpg"}, {Name: "10.jpg"}}
+	images[1] = []chapter.ImageFile{{Name: "1.jpg"}}
+	images[9] = []chapter.ImageFile{{Name: "1.png"}}
+
+	pages, bookmarks := g.Pages(images)
+
+	want := []string{"01_9.jpg", "01_10.jpg", "02_1.jpg", "10_1.png"}
+	for i, name := range want {
+		if i >= len(pages) || pages[i].Name != name {
+			t.Fatalf("pages = %+v, want names %v", pages, want)
+		}
+	}
+
+	// Chapter and page order survive natural sort
+	names := []string{"10_1.png", "02_1.jpg", "01_10.jpg", "01_9.jpg"}
+	sort.Natural(names)
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("natural sort = %v, want %v", names, want)
+			break
+		}
+	}
+
+	wantBookmarks := cbz.PageList{
+		{Image: 0, Bookmark: "Chapter 1: Dawn"},
+		{Image: 2, Bookmark: "c2"},
+		{Image: 3, Bookmark: "Chapter 10"},
+	}
+	if len(bookmarks) != len(wantBookmarks) {
+		t.Fatalf("bookmarks = %+v, want %+v", bookmarks, wantBookmarks)
+	}
+	for i := range wantBookmarks {
+		if bookmarks[i] != wantBookmarks[i] {
+			t.Errorf("bookmarks[%d] = %+v, want %+v", i, bookmarks[i], wantBookmarks[i])
+		}
+	}
+}